@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sabitor/simplelog"
+)
+
+// drift results of a table compared to the baseline
+const (
+	driftUnchanged string = "UNCHANGED" // the checksum equals the recorded checksum
+	driftChanged   string = "CHANGED"   // the checksum differs from the recorded checksum
+	driftNew       string = "NEW"       // the table is not recorded in the baseline
+	driftVanished  string = "VANISHED"  // the recorded table could not be checksummed anymore
+)
+
+// splitTableKey splits a table key of the format <DBMS name>.<instance ID>.<table> into the instance name and the table.
+func splitTableKey(key string) (string, string, bool) {
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return parts[0] + "." + parts[1], parts[2], true
+}
+
+// baselineHeader starts the header line of a baseline file.
+const baselineHeader string = "# " + executableName + " baseline "
+
+// baselineSettings describes the settings which determine the checksums of a run, e.g. "hash=md5 aggregation=v1 structure=false".
+// Checksums of different settings can't be compared.
+func baselineSettings() string {
+	return "hash=" + hashAlgorithm.String() + " aggregation=" + aggregation.String() + " structure=" + strconv.FormatBool(pr.structure)
+}
+
+// writeBaseline records the checksums of all tables in a baseline file.
+// The header line records the time and the settings of the run: # md5tabsum baseline <time> <settings>
+// It is followed by the checksums in the same format as the output of a checksum calculation: <instance>.<table>:<checksum>
+func writeBaseline(file string) error {
+	var lines []string
+	for instance, results := range tableResults {
+		for _, result := range results {
+			if result.err != nil {
+				continue
+			}
+			lines = append(lines, instance+"."+result.table+":"+result.checksum)
+		}
+	}
+	sort.Strings(lines)
+
+	content := baselineHeader + time.Now().Format(time.RFC3339) + " " + baselineSettings() + "\n" + strings.Join(lines, "\n") + "\n"
+	return os.WriteFile(file, []byte(content), 0644)
+}
+
+// readBaseline reads the recorded checksums and the recorded settings of a baseline file.
+// Empty lines and lines starting with # are skipped, except the header line.
+func readBaseline(file string) (map[string]string, string, error) {
+	baseline := make(map[string]string)
+	var settings string
+
+	f, err := os.Open(file)
+	if err != nil {
+		return baseline, settings, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if header, ok := strings.CutPrefix(line, baselineHeader); ok {
+			// the settings follow the time of the run
+			if _, s, ok := strings.Cut(header, " "); ok {
+				settings = strings.TrimSpace(s)
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, ":")
+		if _, _, ok := splitTableKey(line[:max(i, 0)]); i < 0 || !ok {
+			return baseline, settings, errors.New(formatMsg(mm028, file, line))
+		}
+		baseline[line[:i]] = line[i+1:]
+	}
+
+	return baseline, settings, scanner.Err()
+}
+
+// verifyBaseline verifies the checksums of all tables against the checksums recorded in a baseline file.
+// It writes the drift result of each table and returns md5Mismatch if at least one table has changed, is new or has vanished.
+// Recorded tables of instances, which are not active, are not considered. A baseline recorded with other settings
+// (hash algorithm, aggregation version or -structure option) is refused.
+func verifyBaseline(file string) int {
+	baseline, settings, err := readBaseline(file)
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, err.Error())
+		return md5Error
+	}
+	if settings != baselineSettings() {
+		simplelog.Write(simplelog.MULTI, formatMsg(mm068, file, settings, baselineSettings()))
+		return md5Error
+	}
+
+	drifts := make(map[string]string)
+	for instance, results := range tableResults {
+		for _, result := range results {
+			key := instance + "." + result.table
+			if errors.Is(result.err, errInterrupted) {
+				// the checksum of an interrupted table is unknown, the return code reports the interruption
+				drifts[key] = interruptedStatus
+			}
+			if result.err != nil {
+				continue
+			}
+			recorded, found := baseline[key]
+			switch {
+			case !found:
+				drifts[key] = driftNew + " (" + result.checksum + ")"
+			case recorded != result.checksum:
+				drifts[key] = driftChanged + " (" + recorded + " -> " + result.checksum + ")"
+			default:
+				drifts[key] = driftUnchanged
+			}
+		}
+	}
+	for key := range baseline {
+		if instance, _, _ := splitTableKey(key); instanceActive[instance] {
+			if _, found := drifts[key]; !found {
+				drifts[key] = driftVanished
+			}
+		}
+	}
+
+	rc := md5Ok
+	keys := make([]string, 0, len(drifts))
+	for key, drift := range drifts {
+		keys = append(keys, key)
+		if drift != driftUnchanged && drift != interruptedStatus {
+			rc = md5Mismatch
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		simplelog.Write(simplelog.MULTI, key+":"+drifts[key])
+	}
+
+	return rc
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"md5tabsum/checksum"
+)
+
+func TestBaselineSettings(t *testing.T) {
+	defer func(h checksum.Hash, v checksum.Version, structure bool) {
+		hashAlgorithm, aggregation, pr.structure = h, v, structure
+	}(hashAlgorithm, aggregation, pr.structure)
+
+	clear(tableResults)
+	defer clear(tableResults)
+	addResult(tableResult{instance: "sqlite.test", table: "T1", checksum: checksum.Empty})
+	file := filepath.Join(t.TempDir(), "baseline.txt")
+	hashAlgorithm, aggregation, pr.structure = checksum.MD5, checksum.V1, false
+	if err := writeBaseline(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if rc := verifyBaseline(file); rc != md5Ok {
+		t.Errorf("same settings: got return code %d, want %d", rc, md5Ok)
+	}
+	for _, settings := range []func(){
+		func() { hashAlgorithm = checksum.SHA1 },
+		func() { aggregation = checksum.V2 },
+		func() { pr.structure = true },
+	} {
+		hashAlgorithm, aggregation, pr.structure = checksum.MD5, checksum.V1, false
+		settings()
+		if rc := verifyBaseline(file); rc != md5Error {
+			t.Errorf("%s: got return code %d, want %d", baselineSettings(), rc, md5Error)
+		}
+	}
+}
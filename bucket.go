@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+// bucket restricts the rows of a table to a key range or to a row hash bucket.
+// The zero value contains all rows of a table.
+type bucket struct {
+	number      int    // bucket number, 1 to the number of buckets
+	filter      string // key range filter of a key range bucket
+	hashBuckets int    // number of row hash buckets, 0 for a key range bucket
+}
+
+// String describes the rows of a bucket, e.g. "ID >= 1000 and ID < 2000".
+func (b bucket) String() string {
+	switch {
+	case b.hashBuckets > 0:
+		return fmt.Sprintf("row hash mod %d = %d", b.hashBuckets, b.number-1)
+	case b.filter != "":
+		return b.filter
+	default:
+		return "all rows"
+	}
+}
+
+// hashBucket returns the bucket number (0 to buckets-1) of a hash, the remainder of its first 8 hex digits divided by
+// the number of buckets, like the bucket expression of the dialects does.
+func hashBucket(hash string, buckets int) (int, error) {
+	if len(hash) < 8 {
+		return 0, errors.New("invalid hash: " + hash)
+	}
+	part, err := strconv.ParseUint(hash[:8], 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	return int(part % uint64(buckets)), nil
+}
+
+// checksum of a bucket of a table
+type bucketResult struct {
+	bucket   string // description of the rows of the bucket
+	numRows  int
+	checksum string
+}
+
+// buckets splits a table into the configured number of buckets. The buckets are key ranges of equal width between the
+// minimum and maximum value of the numeric bucket key column or, if no bucket key is configured, the rows are distributed
+// by their row hash. Rows with a NULL key belong to the first key range bucket.
+// Hint: The first and the last key range are unbounded, thus all rows are covered even if the table changes meanwhile.
+func (e *engine) buckets(ctx context.Context, q querier, table string, cols []column, opt tableOptions) ([]bucket, error) {
+	buckets := make([]bucket, 0, opt.buckets)
+	if opt.bucketKey == "" {
+		for i := 1; i <= opt.buckets; i++ {
+			buckets = append(buckets, bucket{number: i, hashBuckets: opt.buckets})
+		}
+		return buckets, nil
+	}
+
+	var key *column
+	for i := range cols {
+		if strings.EqualFold(cols[i].name, opt.bucketKey) {
+			key = &cols[i]
+			break
+		}
+	}
+	if key == nil {
+		return buckets, e.logError(errors.New("Bucket key column " + opt.bucketKey + " of table " + table + " could not be found."))
+	}
+	if class := e.dia.typeClass(key.dataType); class == charType || class == dateType || class == timestampType || class == booleanType {
+		return buckets, e.logError(errors.New("Bucket key column " + key.name + " of table " + table + " is not numeric."))
+	}
+
+	keyName := e.dia.quoteIdent(key.name)
+	stmt := e.keyRangeStmt(table, keyName)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[6]: "+stmt)
+	var minKey, maxKey sql.NullString
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	if err := q.QueryRowContext(ctx, stmt).Scan(&minKey, &maxKey); err != nil {
+		return buckets, e.logError(err)
+	}
+	if !minKey.Valid {
+		// the table is empty or all keys are NULL
+		return append(buckets, bucket{number: 1}), nil
+	}
+	low, lowOk := new(big.Rat).SetString(minKey.String)
+	high, highOk := new(big.Rat).SetString(maxKey.String)
+	if !lowOk || !highOk {
+		return buckets, e.logError(errors.New("Bucket key range " + minKey.String + " to " + maxKey.String + " of table " + table + " is not numeric."))
+	}
+
+	// bounds of the key ranges, integer keys are split at integer bounds
+	bounds := make([]string, opt.buckets)
+	width := new(big.Rat).Sub(high, low)
+	for i := 1; i < opt.buckets; i++ {
+		bound := new(big.Rat).Mul(width, big.NewRat(int64(i), int64(opt.buckets)))
+		bound.Add(bound, low)
+		if low.IsInt() && high.IsInt() {
+			bounds[i] = new(big.Int).Div(bound.Num(), bound.Denom()).String()
+		} else {
+			bounds[i] = bound.FloatString(6)
+		}
+	}
+	for i := 1; i <= opt.buckets; i++ {
+		var filter string
+		switch i {
+		case 1:
+			filter = "(" + keyName + " < " + bounds[1] + " or " + keyName + " is NULL)"
+		case opt.buckets:
+			filter = keyName + " >= " + bounds[i-1]
+		default:
+			filter = keyName + " >= " + bounds[i-1] + " and " + keyName + " < " + bounds[i]
+		}
+		buckets = append(buckets, bucket{number: i, filter: filter})
+	}
+	return buckets, nil
+}
+
+// keyRangeStmt builds the statement which queries the minimum and maximum value of the bucket key column of a table.
+func (e *engine) keyRangeStmt(table, keyName string) string {
+	return "select min(" + keyName + "), max(" + keyName + ") from " + e.source(table, "")
+}
+
+// sumsStmt builds the statement which compiles the number of rows and the part sums of the row hashes of a table bucket.
+// The part XORs are compiled as well, if the aggregation version requires them.
+func (e *engine) sumsStmt(table string, cols []column, b bucket) string {
+	exprs := partSumExprs(e.dia)
+	if aggregation == checksum.V2 {
+		exprs = append(exprs, partXorExprs(e.dia)...)
+	}
+	return "select count(1) NUMROWS, " + strings.Join(exprs, ", ") + " from (select " + e.rowHashExpr(cols) + " ROWHASH from " + e.source(table, b.filter) + ") t"
+}
+
+// bucketSumsStmt builds the statement which compiles the number of rows and the part sums of the row hashes of all hash
+// buckets of the rows matching the filter at once. The rows are distributed by the hash of the hash columns or, if no hash
+// columns are given, by their row hash. Empty buckets are not part of the result.
+func (e *engine) bucketSumsStmt(table string, cols []column, filter string, hashCols []column, buckets int) string {
+	exprs := partSumExprs(e.dia)
+	if aggregation == checksum.V2 {
+		exprs = append(exprs, partXorExprs(e.dia)...)
+	}
+	hashes := e.rowHashExpr(cols) + " ROWHASH"
+	hash := "t.ROWHASH"
+	if len(hashCols) > 0 {
+		hashes += ", " + e.rowHashExpr(hashCols) + " KEYHASH"
+		hash = "t.KEYHASH"
+	}
+	bucketExpr := e.dia.bucketExpr(hash, buckets)
+	return "select " + bucketExpr + " BUCKET, count(1) NUMROWS, " + strings.Join(exprs, ", ") + " from (select " + hashes + " from " + e.source(table, filter) + ") t group by " + bucketExpr
+}
+
+// aggregate compiles the aggregate of the row hashes of a table bucket.
+func (e *engine) aggregate(ctx context.Context, q querier, table string, cols []column, b bucket) (checksum.Aggregate, error) {
+	agg := checksum.NewAggregate(hashAlgorithm, aggregation)
+
+	if e.cfg.mode == clientMode {
+		return e.clientAggregate(ctx, q, table, cols, b)
+	}
+
+	stmt := e.sumsStmt(table, cols, b)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[3]: "+stmt)
+	var numRows int64
+	parts := hashAlgorithm.Parts()
+	if aggregation == checksum.V2 {
+		parts *= 2
+	}
+	values := make([]sql.NullString, parts)
+	dest := []any{&numRows}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	if err := q.QueryRowContext(ctx, stmt).Scan(dest...); err != nil {
+		return agg, e.logError(err)
+	}
+	if numRows == 0 {
+		return agg, nil
+	}
+	if err := addSums(&agg, numRows, values); err != nil {
+		return agg, e.logError(fmt.Errorf("Table %s, bucket %s: %w", table, b, err))
+	}
+	return agg, nil
+}
+
+// addSums adds the number of rows and the part sums (and part XORs) queried by a sums statement to an aggregate.
+func addSums(agg *checksum.Aggregate, numRows int64, values []sql.NullString) error {
+	partValues := make([]string, len(values))
+	for i, value := range values {
+		partValues[i] = value.String
+	}
+	return agg.AddSums(numRows, partValues[:hashAlgorithm.Parts()], partValues[hashAlgorithm.Parts():])
+}
+
+// bucketAggregates compiles the aggregates of all hash buckets of the rows of a table matching the filter in a single pass,
+// see bucketSumsStmt. The key of the result is the bucket number (0 to buckets-1), empty buckets are missing.
+func (e *engine) bucketAggregates(ctx context.Context, q querier, table string, cols []column, filter string, hashCols []column, buckets int) (map[int]*checksum.Aggregate, error) {
+	aggs := make(map[int]*checksum.Aggregate)
+
+	if e.cfg.mode == clientMode {
+		return e.clientBucketAggregates(ctx, q, table, cols, filter, hashCols, buckets)
+	}
+
+	stmt := e.bucketSumsStmt(table, cols, filter, hashCols, buckets)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[3]: "+stmt)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := q.QueryContext(ctx, stmt)
+	if err != nil {
+		return aggs, e.logError(err)
+	}
+	defer rowSet.Close()
+
+	var number, numRows int64
+	parts := hashAlgorithm.Parts()
+	if aggregation == checksum.V2 {
+		parts *= 2
+	}
+	values := make([]sql.NullString, parts)
+	dest := []any{&number, &numRows}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	for rowSet.Next() {
+		if err = rowSet.Scan(dest...); err != nil {
+			return aggs, e.logError(err)
+		}
+		agg := checksum.NewAggregate(hashAlgorithm, aggregation)
+		if err = addSums(&agg, numRows, values); err != nil {
+			return aggs, e.logError(fmt.Errorf("Table %s, bucket %d: %w", table, number, err))
+		}
+		aggs[int(number)] = &agg
+	}
+	if err = rowSet.Err(); err != nil {
+		return aggs, e.logError(err)
+	}
+	return aggs, nil
+}
+
+// bucketChecksum compiles the checksums of all buckets of a table. Key range buckets are compiled concurrently, each bucket
+// in a dedicated database session, whereas all row hash buckets are compiled by a single statement.
+// The aggregates of all buckets are combined into the table checksum, which is the same as the checksum of the whole table.
+func (e *engine) bucketChecksum(ctx context.Context, db *sql.DB, table string, cols []column, buckets []bucket) (int, string, []bucketResult, error) {
+	aggs := make([]checksum.Aggregate, len(buckets))
+	if buckets[0].hashBuckets > 0 {
+		conn, err := e.openSession(ctx, db)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		bucketAggs, err := e.bucketAggregates(ctx, conn, table, cols, "", nil, len(buckets))
+		e.closeSession(conn)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		for i := range aggs {
+			aggs[i] = checksum.NewAggregate(hashAlgorithm, aggregation)
+			if agg, found := bucketAggs[i]; found {
+				aggs[i] = *agg
+			}
+		}
+	} else {
+		errs := make([]error, len(buckets))
+		var wg sync.WaitGroup
+		for i, b := range buckets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				conn, err := e.openSession(ctx, db)
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				defer e.closeSession(conn)
+				aggs[i], errs[i] = e.aggregate(ctx, conn, table, cols, b)
+			}()
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return 0, "", nil, err
+		}
+	}
+
+	agg := checksum.NewAggregate(hashAlgorithm, aggregation)
+	results := make([]bucketResult, 0, len(buckets))
+	for i, b := range buckets {
+		agg.Merge(aggs[i])
+		results = append(results, bucketResult{bucket: b.String(), numRows: int(aggs[i].NumRows()), checksum: aggs[i].Checksum()})
+	}
+	return int(agg.NumRows()), agg.Checksum(), results, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/sabitor/simplelog"
+)
+
+// checkCommand checks the connectivity of all active instances.
+const checkCommand string = "check"
+
+// check results of an instance
+const (
+	checkOk     string = "OK"     // the instance is reachable and all table patterns and queries resolve
+	checkFailed string = "FAILED" // the instance is not reachable or at least one table pattern or query doesn't resolve
+)
+
+// connectivity check result of an instance
+type checkResult struct {
+	instance string
+	status   string
+	version  string // version of the DBMS
+	user     string // authenticated user
+	tables   int    // number of tables found by the table patterns, files found by the file pattern respectively
+	problems []string
+}
+
+// checkInstance connects to an instance with the password of the password store and checks that the configured schema,
+// table patterns and queries resolve. File instances are checked by the files matching the file pattern.
+func checkInstance(ctx context.Context, instance string) checkResult {
+	result := checkResult{instance: instance, status: checkOk}
+	fail := func(err error) checkResult {
+		result.status = checkFailed
+		result.problems = append(result.problems, strings.TrimSpace(err.Error()))
+		return result
+	}
+
+	provider, ok := instanceName(instance).(engineProvider)
+	if !ok {
+		if f, isFile := instanceName(instance).(*fileDB); isFile {
+			files, err := filepath.Glob(f.file())
+			if err != nil {
+				return fail(err)
+			}
+			if result.tables = len(files); result.tables == 0 {
+				return fail(errors.New("File " + f.file() + " could not be found."))
+			}
+		}
+		return result
+	}
+
+	db, err := instanceName(instance).openDB(ctx, instancePassword[instance])
+	if err != nil {
+		return fail(interrupted(ctx, err))
+	}
+	defer instanceName(instance).closeDB(db)
+	// the session statements (e.g. NLS settings) apply to the statements of the same session only
+	e := provider.checksumEngine()
+	conn, err := e.openSession(ctx, db)
+	if err != nil {
+		return fail(interrupted(ctx, err))
+	}
+	defer e.closeSession(conn)
+
+	var version, user sql.NullString
+	stmtCtx, cancel := statementContext(ctx)
+	err = conn.QueryRowContext(stmtCtx, e.dia.versionStmt()).Scan(&version, &user)
+	cancel()
+	if err != nil {
+		return fail(interrupted(ctx, err))
+	}
+	result.version, _, _ = strings.Cut(strings.TrimSpace(version.String), "\n")
+	result.user = user.String
+
+	// a schema without any table is considered as missing schema
+	stmt, args := e.dia.tableStmt(e.cfg.schema, "%")
+	if tables, err := e.queryStrings(ctx, conn, stmt, args...); err != nil {
+		return fail(interrupted(ctx, err))
+	} else if len(tables) == 0 {
+		fail(errors.New("Schema " + e.cfg.schema + " could not be found or contains no tables."))
+	}
+	for _, table := range e.cfg.table {
+		stmt, args := e.dia.tableStmt(e.cfg.schema, table)
+		tables, err := e.queryStrings(ctx, conn, stmt, args...)
+		if err != nil {
+			return fail(interrupted(ctx, err))
+		}
+		if len(tables) == 0 {
+			fail(errors.New("Table " + table + " could not be found."))
+		}
+		result.tables += len(tables)
+	}
+	// a query is checked by a statement which returns no rows
+	for _, name := range e.cfg.queryNames() {
+		stmtCtx, cancel := statementContext(ctx)
+		rowSet, err := conn.QueryContext(stmtCtx, "select * from "+e.source(name, "1 = 0"))
+		if err != nil {
+			cancel()
+			if ctx.Err() != nil {
+				return fail(interrupted(ctx, err))
+			}
+			fail(fmt.Errorf("Query %s: %w", name, err))
+			continue
+		}
+		rowSet.Close()
+		cancel()
+	}
+
+	return result
+}
+
+// checkInstances checks the connectivity of all active instances concurrently and writes a summary table to STDOUT.
+// It returns md5Error if the check of at least one instance failed, md5Interrupted if the check was interrupted.
+func checkInstances(ctx context.Context) int {
+	var rc int
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	var results []checkResult
+	for instance := range instanceActive {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := checkInstance(ctx, instance)
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].instance < results[j].instance })
+
+	var out strings.Builder
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tSTATUS\tVERSION\tUSER\tTABLES\tPROBLEMS")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", r.instance, r.status, r.version, r.user, r.tables, strings.Join(r.problems, " "))
+		simplelog.Write(simplelog.FILE, "["+r.instance+"] -", "Check:", r.status+",", "Version:", r.version+",", "User:", r.user+",", "Tables:", r.tables, strings.Join(r.problems, " "))
+		if r.status != checkOk {
+			rc = errorCode(ctx)
+		}
+	}
+	w.Flush()
+	simplelog.Write(simplelog.STDOUT, strings.TrimSuffix(out.String(), "\n"))
+
+	return rc
+}
@@ -0,0 +1,226 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is the Go layout of the canonical timestamp string 'YYYY-MM-DD HH24:MI:SS.FF6'.
+const TimestampLayout string = "2006-01-02 15:04:05.000000"
+
+// timestampLayouts lists all supported layouts of timestamp and date strings.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02",
+}
+
+// ParseClass maps a generic data type name, e.g. varchar or decimal(18,2), to its type class.
+func ParseClass(dataType string) (Class, error) {
+	name, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(dataType)), "(")
+	switch strings.TrimSpace(name) {
+	case "char", "character", "varchar", "varchar2", "nchar", "nvarchar", "text", "string", "clob":
+		return Char, nil
+	case "decimal", "numeric", "number", "integer", "int", "smallint", "bigint", "tinyint":
+		return Decimal, nil
+	case "float", "double", "double precision", "real":
+		return Float, nil
+	case "date":
+		return Date, nil
+	case "timestamp", "datetime", "datetime2":
+		return Timestamp, nil
+	case "boolean", "bool", "bit":
+		return Boolean, nil
+	default:
+		return Other, errors.New("unsupported data type: " + dataType)
+	}
+}
+
+// md5Hex returns the MD5 of a string as 32 lowercase hex digits.
+func md5Hex(s string) string {
+	hash := md5.Sum([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+// Canonical converts a column value into its canonical string, which equals the result of the canonical column expression of the DBMS.
+// NULL values (nil) are represented by 'null'. Character values are represented by their MD5, see Hash.Canonical.
+//
+// Supported values are nil, []byte, string, bool, integers, float32, float64, *big.Int, time.Time and
+// all values, which are supported by a registered converter.
+func Canonical(class Class, value any) (string, error) {
+	return MD5.Canonical(class, value)
+}
+
+// Canonical converts a column value into its canonical string like the package function Canonical, but character
+// values are represented by their hash of the hash algorithm h.
+func (h Hash) Canonical(class Class, value any) (string, error) {
+	if value == nil {
+		return "null", nil
+	}
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	for _, convert := range converters {
+		if v, ok := convert(value); ok {
+			value = v
+			break
+		}
+	}
+
+	switch class {
+	case Char:
+		return h.Sum(strings.TrimRight(fmt.Sprint(value), " ")), nil
+	case Decimal:
+		return decimalString(value)
+	case Float:
+		return floatString(value)
+	case Date, Timestamp:
+		return timestampString(value)
+	case Boolean:
+		return booleanString(value)
+	default:
+		switch v := value.(type) {
+		case float64:
+			return formatFloat(v, 64), nil
+		case float32:
+			return formatFloat(float64(v), 32), nil
+		case bool:
+			return booleanString(v)
+		case time.Time:
+			return v.Format(TimestampLayout), nil
+		default:
+			return fmt.Sprint(v), nil
+		}
+	}
+}
+
+// decimalString converts an exact numeric value into a string without trailing zeros, e.g. 0.90 becomes 0.9 and 1.00 becomes 1.
+func decimalString(value any) (string, error) {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case *big.Int:
+		return v.String(), nil
+	case string:
+		s := strings.TrimSpace(v)
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return "", errors.New("invalid numeric value: " + v)
+		}
+		// the number of fractional digits required to represent the value exactly
+		mantissa, exponent, _ := strings.Cut(strings.ToLower(s), "e")
+		_, fraction, _ := strings.Cut(mantissa, ".")
+		exp, _ := strconv.Atoi(exponent)
+		return trimFraction(r.FloatString(max(0, len(fraction)-exp))), nil
+	default:
+		return "", fmt.Errorf("unsupported numeric value type %T", v)
+	}
+}
+
+// trimFraction removes trailing zeros of the fractional part of a decimal string, -0 becomes 0.
+func trimFraction(s string) string {
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// floatString converts an approximate numeric value into its shortest string representation.
+func floatString(value any) (string, error) {
+	switch v := value.(type) {
+	case float64:
+		return formatFloat(v, 64), nil
+	case float32:
+		return formatFloat(float64(v), 32), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return "", err
+		}
+		return formatFloat(f, 64), nil
+	default:
+		return decimalString(v)
+	}
+}
+
+// formatFloat formats a floating point number like PostgreSQL does: the shortest representation which reads back exactly,
+// the exponential notation is used if the exponent is less than -4 or greater than or equal to 15, e.g. 1e-05 or 1e+15.
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	scientific := strconv.FormatFloat(f, 'e', -1, bitSize)
+	_, exponent, _ := strings.Cut(scientific, "e")
+	exp, _ := strconv.Atoi(exponent)
+	if exp < -4 || exp >= 15 {
+		// PostgreSQL uses at least two exponent digits, e.g. 1e-05
+		return scientific
+	}
+	return strconv.FormatFloat(f, 'f', -1, bitSize)
+}
+
+// timestampString converts a date or timestamp value into the canonical format 'YYYY-MM-DD HH24:MI:SS.FF6'.
+func timestampString(value any) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(TimestampLayout), nil
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.Format(TimestampLayout), nil
+			}
+		}
+		return "", errors.New("invalid timestamp value: " + v)
+	default:
+		return "", fmt.Errorf("unsupported timestamp value type %T", v)
+	}
+}
+
+// booleanString converts a boolean value into 1 or 0.
+func booleanString(value any) (string, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case int64:
+		return booleanString(v != 0)
+	case int32:
+		return booleanString(v != 0)
+	case int:
+		return booleanString(v != 0)
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "1", "t", "true", "y", "yes":
+			return "1", nil
+		case "0", "f", "false", "n", "no":
+			return "0", nil
+		}
+		return "", errors.New("invalid boolean value: " + v)
+	default:
+		return "", fmt.Errorf("unsupported boolean value type %T", v)
+	}
+}
@@ -0,0 +1,207 @@
+// Package checksum is the reference implementation of the md5tabsum table checksum.
+//
+// Every column value of a row is converted into its canonical string (see Canonical), the canonical strings of all
+// columns are concatenated in the order of the table columns and the MD5 of the result is the row hash (see RowHash).
+// The row hashes of all rows are aggregated into the table checksum (see Aggregate): each row hash is split into four
+// parts of 8 hex digits, the parts are summed up separately as unsigned integers and the table checksum is the MD5 of the
+// concatenated decimal sums. The checksum of an empty table is the MD5 of an empty string.
+//
+// Besides MD5, SHA-1 and SHA-256 are supported (see Hash): the canonical strings of character values, the row hashes
+// and the table checksum are hashed by the selected algorithm, the row hashes are split into 5 or 8 parts respectively.
+//
+// The aggregation of the row hashes is versioned (see Version): instead of the part sums, V2 hashes the number of rows,
+// the modular sum and the XOR of all row hashes.
+//
+// The row order doesn't matter, thus the checksum of a table compiled by a DBMS can be verified by
+//
+//	var agg checksum.Aggregate
+//	for _, row := range rows {
+//		if err := agg.AddRow(classes, row); err != nil {
+//			return err
+//		}
+//	}
+//	fmt.Println(agg.NumRows(), agg.Checksum())
+package checksum
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Empty is the checksum of an empty table (MD5 of an empty string).
+const Empty string = "d41d8cd98f00b204e9800998ecf8427e"
+
+// Class groups DBMS specific column data types which share the same canonical string representation.
+type Class int
+
+const (
+	Other     Class = iota // any other data type, converted by a plain cast into a string
+	Char                   // character data types, represented by the hash (MD5 by default) of the right trimmed value
+	Decimal                // exact numeric data types, represented without trailing zeros
+	Float                  // approximate numeric data types
+	Date                   // date data types without a time part
+	Timestamp              // time and timestamp data types, represented as 'YYYY-MM-DD HH24:MI:SS.FF6'
+	Boolean                // boolean data types, represented as 1 or 0
+)
+
+// converters convert driver specific column values into values supported by Canonical.
+var converters []func(value any) (any, bool)
+
+// RegisterConverter registers a converter for driver specific column values, e.g. the DECIMAL values of a database driver.
+// The converter returns false if it doesn't support the value.
+func RegisterConverter(convert func(value any) (any, bool)) {
+	converters = append(converters, convert)
+}
+
+// RowHash returns the MD5 of the concatenated canonical strings of all columns of a row.
+func RowHash(canonicalColumns []string) string {
+	return MD5.RowHash(canonicalColumns)
+}
+
+// Row returns the MD5 row hash of the column values of a row, classes contains the type class of each column.
+func Row(classes []Class, values []any) (string, error) {
+	return MD5.Row(classes, values)
+}
+
+// Row returns the row hash of the column values of a row like the package function Row, but of the hash algorithm h.
+func (h Hash) Row(classes []Class, values []any) (string, error) {
+	if len(classes) != len(values) {
+		return "", errors.New("the number of values (" + strconv.Itoa(len(values)) + ") doesn't match the number of columns (" + strconv.Itoa(len(classes)) + ")")
+	}
+	canonicalColumns := make([]string, len(values))
+	for i, value := range values {
+		s, err := h.Canonical(classes[i], value)
+		if err != nil {
+			return "", errors.New("column " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		canonicalColumns[i] = s
+	}
+	return h.RowHash(canonicalColumns), nil
+}
+
+// Aggregate aggregates row hashes into a table checksum, the zero value is an empty table of MD5 row hashes (V1).
+// The 8 hex digit parts of all row hashes are summed up and XORed separately, the checksum is the hash of the concatenated
+// sums (V1) or the hash of the number of rows, the modular sum and the XOR of all row hashes (V2).
+type Aggregate struct {
+	hash    Hash
+	version Version
+	numRows int64
+	sums    [8][2]uint64 // 128 bit sums (high, low) of the parts, 4 parts of MD5, 5 of SHA-1 and 8 of SHA-256
+	xors    [8]uint32    // bitwise XOR of the parts
+}
+
+// NewAggregate returns an empty aggregate of row hashes of the hash algorithm h, which are aggregated by version v.
+func NewAggregate(h Hash, v Version) Aggregate {
+	return Aggregate{hash: h, version: v}
+}
+
+// Add adds a row hash (32 hex digits for MD5) to the aggregate.
+func (a *Aggregate) Add(rowHash string) error {
+	if len(rowHash) != a.hash.Size() {
+		return errors.New("invalid row hash: " + rowHash)
+	}
+	for i := range a.hash.Parts() {
+		part, err := strconv.ParseUint(rowHash[i*8:i*8+8], 16, 32)
+		if err != nil {
+			return err
+		}
+		var carry uint64
+		a.sums[i][1], carry = bits.Add64(a.sums[i][1], part, 0)
+		a.sums[i][0] += carry
+		a.xors[i] ^= uint32(part)
+	}
+	a.numRows++
+	return nil
+}
+
+// AddSums adds a partial aggregate compiled elsewhere, e.g. by a DBMS, given by its number of rows, the decimal sums
+// and the decimal XORs of the parts. It allows to compile the checksum of a table from the checksums of its buckets.
+// The XORs are required by V2 only, they may be nil for V1.
+func (a *Aggregate) AddSums(numRows int64, sums, xors []string) error {
+	if len(sums) != a.hash.Parts() {
+		return errors.New("the number of sums (" + strconv.Itoa(len(sums)) + ") doesn't match the number of parts (" + strconv.Itoa(a.hash.Parts()) + ")")
+	}
+	if a.version == V2 && len(xors) != a.hash.Parts() {
+		return errors.New("the number of XORs (" + strconv.Itoa(len(xors)) + ") doesn't match the number of parts (" + strconv.Itoa(a.hash.Parts()) + ")")
+	}
+	b := NewAggregate(a.hash, a.version)
+	b.numRows = numRows
+	for i, s := range sums {
+		sum, ok := new(big.Int).SetString(s, 10)
+		if !ok || sum.Sign() < 0 || sum.BitLen() > 128 {
+			return errors.New("invalid sum: " + s)
+		}
+		b.sums[i][1] = sum.Uint64()
+		b.sums[i][0] = sum.Rsh(sum, 64).Uint64()
+	}
+	for i, s := range xors {
+		xor, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return errors.New("invalid XOR: " + s)
+		}
+		b.xors[i] = uint32(xor)
+	}
+	a.Merge(b)
+	return nil
+}
+
+// Merge adds another aggregate of the same hash algorithm, the result is the aggregate of the rows of both aggregates.
+func (a *Aggregate) Merge(b Aggregate) {
+	for i := range a.sums {
+		var carry uint64
+		a.sums[i][1], carry = bits.Add64(a.sums[i][1], b.sums[i][1], 0)
+		a.sums[i][0], _ = bits.Add64(a.sums[i][0], b.sums[i][0], carry)
+		a.xors[i] ^= b.xors[i]
+	}
+	a.numRows += b.numRows
+}
+
+// AddRow adds the row hash of the column values of a row to the aggregate, see Row.
+func (a *Aggregate) AddRow(classes []Class, values []any) error {
+	rowHash, err := a.hash.Row(classes, values)
+	if err != nil {
+		return err
+	}
+	return a.Add(rowHash)
+}
+
+// NumRows returns the number of aggregated rows.
+func (a *Aggregate) NumRows() int64 {
+	return a.numRows
+}
+
+// Checksum returns the table checksum, the hash of an empty string for an empty table (Empty for MD5).
+func (a *Aggregate) Checksum() string {
+	if a.numRows == 0 {
+		return a.hash.Empty()
+	}
+	sums := make([]*big.Int, 0, a.hash.Parts())
+	for _, sum := range a.sums[:a.hash.Parts()] {
+		high := new(big.Int).Lsh(new(big.Int).SetUint64(sum[0]), 64)
+		sums = append(sums, high.Or(high, new(big.Int).SetUint64(sum[1])))
+	}
+
+	var s strings.Builder
+	switch a.version {
+	case V2:
+		// the sum of all row hashes is the sum of the part sums weighted by the position of the parts
+		rowHashSum := new(big.Int)
+		for _, sum := range sums {
+			rowHashSum.Add(rowHashSum.Lsh(rowHashSum, 32), sum)
+		}
+		rowHashSum.Mod(rowHashSum, new(big.Int).Lsh(big.NewInt(1), uint(a.hash.Size()*4)))
+		fmt.Fprintf(&s, "%d:%0*x:", a.numRows, a.hash.Size(), rowHashSum)
+		for _, xor := range a.xors[:a.hash.Parts()] {
+			fmt.Fprintf(&s, "%08x", xor)
+		}
+	default:
+		for _, sum := range sums {
+			s.WriteString(sum.String())
+		}
+	}
+	return a.hash.Sum(s.String())
+}
@@ -0,0 +1,410 @@
+package checksum
+
+import (
+	"bufio"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// t1 is a T1 table parsed from a test data SQL script.
+type t1 struct {
+	classes []Class
+	rows    [][]any
+}
+
+// goldenFile returns the golden file of T1 of a hash algorithm, e.g. T1.sha1.golden. The MD5 golden file is T1.golden.
+func goldenFile(h Hash) string {
+	if h == MD5 {
+		return filepath.Join("testdata", "T1.golden")
+	}
+	return filepath.Join("testdata", "T1."+h.String()+".golden")
+}
+
+// readGolden returns the golden row hashes of T1 and its checksum of a hash algorithm.
+func readGolden(t *testing.T, h Hash) ([]string, string) {
+	t.Helper()
+	fh, err := os.Open(goldenFile(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) < 1 {
+		t.Fatal("empty golden file")
+	}
+	return lines[:len(lines)-1], lines[len(lines)-1]
+}
+
+// split splits a SQL list at a separator, which is neither part of a string literal nor of parentheses.
+func split(list, sep string) []string {
+	var items []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(list); i++ {
+		switch {
+		case list[i] == '\'':
+			quoted = !quoted // an escaped quote ('') toggles twice
+		case quoted:
+		case list[i] == '(':
+			depth++
+		case list[i] == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(list[i:], sep):
+			items = append(items, strings.TrimSpace(list[start:i]))
+			start = i + len(sep)
+		}
+	}
+	return append(items, strings.TrimSpace(list[start:]))
+}
+
+// sqlValue converts a SQL literal into a Go value as returned by a database driver.
+// String literals can be concatenated (||) and include chr(n) calls, backslashes are escape characters in MySQL.
+func sqlValue(literal string, backslashEscapes bool) any {
+	switch {
+	case strings.EqualFold(literal, "null"):
+		return nil
+	case strings.EqualFold(literal, "true"):
+		return true
+	case strings.EqualFold(literal, "false"):
+		return false
+	case strings.HasPrefix(literal, "'"), strings.HasPrefix(strings.ToLower(literal), "chr("):
+		var s strings.Builder
+		for _, part := range split(literal, "||") {
+			if code, ok := strings.CutPrefix(strings.ToLower(part), "chr("); ok {
+				n, _ := strconv.Atoi(strings.TrimSuffix(code, ")"))
+				s.WriteRune(rune(n))
+				continue
+			}
+			part = strings.ReplaceAll(part[1:len(part)-1], "''", "'")
+			if backslashEscapes {
+				part = strings.ReplaceAll(part, "\\\\", "\\")
+			}
+			s.WriteString(part)
+		}
+		return s.String()
+	default:
+		return []byte(literal)
+	}
+}
+
+// readT1 parses the CREATE TABLE and INSERT statements of T1 in a test data SQL script.
+func readT1(t *testing.T, file string) t1 {
+	t.Helper()
+	script, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var table t1
+	for _, stmt := range strings.Split(string(script), ";") {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case strings.HasPrefix(strings.ToUpper(stmt), "CREATE TABLE T1"):
+			columns := stmt[strings.Index(stmt, "(")+1 : strings.LastIndex(stmt, ")")]
+			for _, column := range split(columns, ",") {
+				_, dataType, _ := strings.Cut(column, " ")
+				class, err := ParseClass(dataType)
+				if err != nil {
+					t.Fatalf("%s: %v", file, err)
+				}
+				table.classes = append(table.classes, class)
+			}
+		case strings.HasPrefix(strings.ToUpper(stmt), "INSERT INTO T1"):
+			values := stmt[strings.Index(stmt, "VALUES (")+8 : strings.LastIndex(stmt, ")")]
+			var row []any
+			for _, literal := range split(values, ",") {
+				row = append(row, sqlValue(literal, filepath.Base(file) == "mysql.sql"))
+			}
+			table.rows = append(table.rows, row)
+		}
+	}
+	if len(table.classes) == 0 || len(table.rows) == 0 {
+		t.Fatalf("%s: table T1 could not be found", file)
+	}
+	return table
+}
+
+func TestT1Golden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "testdata", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test data found")
+	}
+
+	for _, h := range []Hash{MD5, SHA1, SHA256} {
+		goldenRowHashes, goldenChecksum := readGolden(t, h)
+		for _, file := range files {
+			t.Run(h.String()+"/"+filepath.Base(file), func(t *testing.T) {
+				table := readT1(t, file)
+				if len(table.rows) != len(goldenRowHashes) {
+					t.Fatalf("got %d rows, want %d", len(table.rows), len(goldenRowHashes))
+				}
+
+				agg := NewAggregate(h, V1)
+				for i, row := range table.rows {
+					rowHash, err := h.Row(table.classes, row)
+					if err != nil {
+						t.Fatalf("row %d: %v", i+1, err)
+					}
+					if rowHash != goldenRowHashes[i] {
+						t.Errorf("row %d: got row hash %s, want %s", i+1, rowHash, goldenRowHashes[i])
+					}
+					if err = agg.Add(rowHash); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if agg.NumRows() != int64(len(goldenRowHashes)) {
+					t.Errorf("got %d rows, want %d", agg.NumRows(), len(goldenRowHashes))
+				}
+				if agg.Checksum() != goldenChecksum {
+					t.Errorf("got checksum %s, want %s", agg.Checksum(), goldenChecksum)
+				}
+			})
+		}
+	}
+}
+
+func TestRowOrder(t *testing.T) {
+	table := readT1(t, filepath.Join("..", "testdata", "postgresql.sql"))
+
+	var forward, backward Aggregate
+	for i := range table.rows {
+		if err := forward.AddRow(table.classes, table.rows[i]); err != nil {
+			t.Fatal(err)
+		}
+		if err := backward.AddRow(table.classes, table.rows[len(table.rows)-1-i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if forward.Checksum() != backward.Checksum() {
+		t.Errorf("checksum depends on the row order: %s != %s", forward.Checksum(), backward.Checksum())
+	}
+}
+
+// goldenV2 is the V2 checksum of the golden row hashes of T1.
+const goldenV2 string = "9dd17b4e5123503b753f5795d977e24a"
+
+func TestMerge(t *testing.T) {
+	rowHashes, golden := readGolden(t, MD5)
+
+	for version, want := range map[Version]string{V1: golden, V2: goldenV2} {
+		var buckets [3]Aggregate
+		for i := range buckets {
+			buckets[i] = NewAggregate(MD5, version)
+		}
+		for i, rowHash := range rowHashes {
+			if err := buckets[i%len(buckets)].Add(rowHash); err != nil {
+				t.Fatal(err)
+			}
+		}
+		merged, summed := NewAggregate(MD5, version), NewAggregate(MD5, version)
+		for _, bucket := range buckets {
+			merged.Merge(bucket)
+			sums := make([]string, MD5.Parts())
+			xors := make([]string, MD5.Parts())
+			for i, sum := range bucket.sums[:MD5.Parts()] {
+				sums[i] = new(big.Int).SetUint64(sum[1]).String()
+				xors[i] = strconv.FormatUint(uint64(bucket.xors[i]), 10)
+				if sum[0] != 0 {
+					t.Fatal("unexpected 128 bit sum")
+				}
+			}
+			if err := summed.AddSums(bucket.NumRows(), sums, xors); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, agg := range []Aggregate{merged, summed} {
+			if agg.NumRows() != int64(len(rowHashes)) || agg.Checksum() != want {
+				t.Errorf("%s: got %d rows and checksum %s, want %d rows and checksum %s", version, agg.NumRows(), agg.Checksum(), len(rowHashes), want)
+			}
+		}
+	}
+
+	var agg Aggregate
+	for _, sum := range []string{"", "-1", "abc", "340282366920938463463374607431768211456"} {
+		if err := agg.AddSums(1, []string{sum, "0", "0", "0"}, nil); err == nil {
+			t.Errorf("AddSums(%q): want an error", sum)
+		}
+	}
+	if err := agg.AddSums(1, []string{"0", "0", "0", "0", "0"}, nil); err == nil {
+		t.Error("AddSums with 5 sums: want an error")
+	}
+	agg = NewAggregate(MD5, V2)
+	if err := agg.AddSums(1, []string{"0", "0", "0", "0"}, nil); err == nil {
+		t.Error("AddSums of V2 without XORs: want an error")
+	}
+	if err := agg.AddSums(1, []string{"0", "0", "0", "0"}, []string{"4294967296", "0", "0", "0"}); err == nil {
+		t.Error("AddSums of V2 with an invalid XOR: want an error")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for s, want := range map[string]Version{"1": V1, "2": V2, "v2": V2, "V1": V1} {
+		if got, err := ParseVersion(s); err != nil || got != want {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "0", "3", "x"} {
+		if _, err := ParseVersion(s); err == nil {
+			t.Errorf("ParseVersion(%q): want an error", s)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var agg Aggregate
+	if agg.NumRows() != 0 || agg.Checksum() != Empty {
+		t.Errorf("got %d rows and checksum %s, want 0 rows and checksum %s", agg.NumRows(), agg.Checksum(), Empty)
+	}
+	if md5Hex("") != Empty {
+		t.Errorf("got %s, want the MD5 of an empty string", Empty)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	ts := time.Date(2012, 4, 9, 14, 3, 33, 727000000, time.UTC)
+	tests := []struct {
+		class Class
+		value any
+		want  string
+	}{
+		{Char, nil, "null"},
+		{Char, "asdf", "912ec803b2ce49e4a541068d495ab570"},
+		{Char, "asdf    ", "912ec803b2ce49e4a541068d495ab570"},
+		{Char, []byte("asdf"), "912ec803b2ce49e4a541068d495ab570"},
+		{Decimal, "0.90", "0.9"},
+		{Decimal, "1.00", "1"},
+		{Decimal, "-0.00", "0"},
+		{Decimal, "1.5E2", "150"},
+		{Decimal, int64(42), "42"},
+		{Decimal, big.NewInt(-7), "-7"},
+		{Float, 40.1234, "40.1234"},
+		{Float, 8192.0, "8192"},
+		{Float, float32(0.1), "0.1"},
+		{Float, 0.00001, "1e-05"},
+		{Float, 1e15, "1e+15"},
+		{Float, "32001.01", "32001.01"},
+		{Date, "2012-04-09", "2012-04-09 00:00:00.000000"},
+		{Timestamp, "2012-04-09 14:03:33.727", "2012-04-09 14:03:33.727000"},
+		{Timestamp, ts, "2012-04-09 14:03:33.727000"},
+		{Boolean, true, "1"},
+		{Boolean, int64(0), "0"},
+		{Boolean, "false", "0"},
+		{Other, 8192.0, "8192"},
+		{Other, "text", "text"},
+	}
+	for _, test := range tests {
+		got, err := Canonical(test.class, test.value)
+		if err != nil {
+			t.Errorf("Canonical(%d, %#v): %v", test.class, test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Canonical(%d, %#v) = %q, want %q", test.class, test.value, got, test.want)
+		}
+	}
+}
+
+func TestCanonicalInvalid(t *testing.T) {
+	tests := []struct {
+		class Class
+		value any
+	}{
+		{Decimal, "abc"},
+		{Float, "abc"},
+		{Timestamp, "09.04.2012"},
+		{Boolean, "maybe"},
+		{Timestamp, 42},
+	}
+	for _, test := range tests {
+		if got, err := Canonical(test.class, test.value); err == nil {
+			t.Errorf("Canonical(%d, %#v) = %q, want an error", test.class, test.value, got)
+		}
+	}
+}
+
+func TestParseClass(t *testing.T) {
+	tests := map[string]Class{
+		"VARCHAR(20)":      Char,
+		"char(8)":          Char,
+		"DECIMAL(18,2)":    Decimal,
+		"NUMBER(18, 2)":    Decimal,
+		"integer":          Decimal,
+		"DOUBLE PRECISION": Float,
+		"float":            Float,
+		"DATE":             Date,
+		"TIMESTAMP(6)":     Timestamp,
+		"DATETIME2":        Timestamp,
+		"BOOLEAN":          Boolean,
+		"BIT":              Boolean,
+	}
+	for dataType, want := range tests {
+		got, err := ParseClass(dataType)
+		if err != nil {
+			t.Errorf("ParseClass(%q): %v", dataType, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseClass(%q) = %d, want %d", dataType, got, want)
+		}
+	}
+	if _, err := ParseClass("BLOB"); err == nil {
+		t.Error("ParseClass(\"BLOB\"): want an error")
+	}
+}
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		hash     Hash
+		empty    string
+		checksum string
+	}{
+		{"md5", MD5, Empty, "d39b7f9ac9adcca3561c398636f3c9a1"},
+		{"SHA1", SHA1, "da39a3ee5e6b4b0d3255bfef95601890afd80709", "1a51b73f0f9f2be214f029f8edc14ba4119f5388"},
+		{"sha256", SHA256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "647ab75e2051c7922a93061902e221097f0aa11f8328bc3d6923613fc1169907"},
+	}
+	classes := []Class{Decimal, Char}
+	rows := [][]any{{int64(1), "abc"}, {int64(2), nil}, {int64(3), "  "}}
+	for _, test := range tests {
+		h, err := ParseHash(test.name)
+		if err != nil || h != test.hash {
+			t.Errorf("ParseHash(%q) = %v, %v, want %v", test.name, h, err, test.hash)
+			continue
+		}
+		agg := NewAggregate(h, V1)
+		if agg.Checksum() != test.empty || len(test.empty) != h.Size() {
+			t.Errorf("%s: got empty checksum %s, want %s", h, agg.Checksum(), test.empty)
+		}
+		for _, row := range rows {
+			if err := agg.AddRow(classes, row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if agg.Checksum() != test.checksum {
+			t.Errorf("%s: got checksum %s, want %s", h, agg.Checksum(), test.checksum)
+		}
+		if err := agg.Add(Empty); h != MD5 && err == nil {
+			t.Errorf("%s: Add of a MD5 row hash: want an error", h)
+		}
+	}
+	if _, err := ParseHash("crc32"); err == nil {
+		t.Error("ParseHash(\"crc32\"): want an error")
+	}
+}
@@ -0,0 +1,78 @@
+package checksum
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Hash is the hash algorithm of the canonical strings of character columns, the row hashes and the table checksum.
+// The zero value is MD5.
+type Hash int
+
+const (
+	MD5    Hash = iota // 32 hex digits
+	SHA1               // 40 hex digits
+	SHA256             // 64 hex digits
+)
+
+// hashNames are the names of the hash algorithms, see ParseHash.
+var hashNames = map[Hash]string{MD5: "md5", SHA1: "sha1", SHA256: "sha256"}
+
+// ParseHash returns the hash algorithm of a name: md5, sha1 or sha256 (case-insensitive).
+func ParseHash(name string) (Hash, error) {
+	for h, hashName := range hashNames {
+		if strings.EqualFold(name, hashName) {
+			return h, nil
+		}
+	}
+	return MD5, errors.New("unsupported hash algorithm: " + name)
+}
+
+// String returns the name of the hash algorithm.
+func (h Hash) String() string {
+	return hashNames[h]
+}
+
+// Sum returns the hash of a string as lowercase hex digits.
+func (h Hash) Sum(s string) string {
+	switch h {
+	case SHA1:
+		hash := sha1.Sum([]byte(s))
+		return hex.EncodeToString(hash[:])
+	case SHA256:
+		hash := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(hash[:])
+	default:
+		return md5Hex(s)
+	}
+}
+
+// Size returns the number of hex digits of a hash.
+func (h Hash) Size() int {
+	switch h {
+	case SHA1:
+		return 40
+	case SHA256:
+		return 64
+	default:
+		return 32
+	}
+}
+
+// Parts returns the number of 8 hex digit parts of a row hash, which are summed up separately by an Aggregate.
+func (h Hash) Parts() int {
+	return h.Size() / 8
+}
+
+// Empty returns the checksum of an empty table, which is the hash of an empty string.
+func (h Hash) Empty() string {
+	return h.Sum("")
+}
+
+// RowHash returns the hash of the concatenated canonical strings of all columns of a row.
+func (h Hash) RowHash(canonicalColumns []string) string {
+	return h.Sum(strings.Join(canonicalColumns, ""))
+}
@@ -0,0 +1,36 @@
+package checksum
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Version is the version of the aggregation of the row hashes into the table checksum.
+// The zero value is V1.
+type Version int
+
+const (
+	// V1 is the hash of the concatenated decimal sums of the 8 hex digit parts of all row hashes.
+	V1 Version = iota + 1
+	// V2 is the hash of the number of rows, the sum of all row hashes modulo 2^bits (128 bits for MD5) and the
+	// bitwise XOR of all row hashes. Distinct sets of rows are much less likely to collide than by the part sums of V1.
+	V2
+)
+
+// ParseVersion returns the aggregation version of a number (1 or 2), optionally prefixed by v.
+func ParseVersion(s string) (Version, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(s), "v"))
+	if err != nil || n < int(V1) || n > int(V2) {
+		return V1, errors.New("unsupported aggregation version: " + s)
+	}
+	return Version(n), nil
+}
+
+// String returns the name of the aggregation version, e.g. v2.
+func (v Version) String() string {
+	if v == 0 {
+		v = V1
+	}
+	return "v" + strconv.Itoa(int(v))
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sabitor/simplelog"
+)
+
+// compareCommand compares the checksums of the table pairs of the Compare section.
+const compareCommand string = "compare"
+
+// compare results of a table pair
+const (
+	compareMatch    string = "MATCH"    // both tables have the same checksum
+	compareMismatch string = "MISMATCH" // the tables have different checksums
+	compareMissing  string = "MISSING"  // the table of one side could not be checksummed
+)
+
+// collection of compare pair config attributes
+type comparePair struct {
+	name    string
+	source  string                  // source instance, e.g. oracle.prod
+	target  string                  // target instance, e.g. postgresql.new
+	table   []string                // tables to be compared, all tables of both instances if not set
+	mapping map[string]tableMapping // table mappings of the Mapping section, the key is the upper case source table
+}
+
+// mapping of a source table to its target table
+type tableMapping struct {
+	target        string   // target table
+	sourceColumns []string // source columns in the order of concatenation, the physical column order if not set
+	targetColumns []string // target columns corresponding to the source columns
+}
+
+// targetTable returns the target table of a source table, which is the same table name if the table isn't mapped.
+func (p comparePair) targetTable(table string) string {
+	if m, found := p.mapping[strings.ToUpper(table)]; found {
+		return m.target
+	}
+	return table
+}
+
+// mappingActive returns true if the table mappings of the compare pairs are applied, which is the case for the compare
+// and diff commands only. All other runs checksum the tables as configured by their instances.
+func mappingActive() bool {
+	return pr.command == compareCommand || pr.command == diffCommand
+}
+
+// mappedColumns returns the columns of a table in the order of concatenation defined by a table mapping, nil if no
+// column mapping is defined for the table or the mappings aren't applied.
+func mappedColumns(instance, table string) []string {
+	if !mappingActive() {
+		return nil
+	}
+	for _, pair := range comparePairs {
+		for source, m := range pair.mapping {
+			switch {
+			case pair.source == instance && strings.EqualFold(source, table):
+				return m.sourceColumns
+			case pair.target == instance && strings.EqualFold(m.target, table):
+				return m.targetColumns
+			}
+		}
+	}
+	return nil
+}
+
+// sourceColumns returns the source column names of the mapped columns of a table, the key is the upper case column
+// name of the table, which can be the source or the target table of the mapping. It returns nil, if the columns of the
+// table aren't mapped or the mappings aren't applied.
+func sourceColumns(instance, table string) map[string]string {
+	mapped := mappedColumns(instance, table)
+	if len(mapped) == 0 {
+		return nil
+	}
+	for _, pair := range comparePairs {
+		for source, m := range pair.mapping {
+			if (pair.source == instance && strings.EqualFold(source, table)) || (pair.target == instance && strings.EqualFold(m.target, table)) {
+				names := make(map[string]string, len(mapped))
+				for i, col := range mapped {
+					names[strings.ToUpper(col)] = m.sourceColumns[i]
+				}
+				return names
+			}
+		}
+	}
+	return nil
+}
+
+// findResult returns the table result of an instance, table names are compared case-insensitive.
+// Hint: The case of table names can differ between DBMS, e.g. T1 in Oracle is t1 in PostgreSQL.
+func findResult(instance, table string) (tableResult, bool) {
+	for _, result := range tableResults[instance] {
+		if result.err == nil && result.table == table {
+			return result, true
+		}
+	}
+	for _, result := range tableResults[instance] {
+		if result.err == nil && strings.EqualFold(result.table, table) {
+			return result, true
+		}
+	}
+	return tableResult{}, false
+}
+
+// targetColumns translates source columns into the target columns of a column mapping. The source and target tables are
+// specified as <instance name>.<table>, the columns are returned unchanged if no compare pair maps the source table to the target table.
+func targetColumns(source, target string, cols []string) []string {
+	sourceInstance, sourceTable, _ := splitTableKey(source)
+	targetInstance, targetTable, _ := splitTableKey(target)
+	for _, pair := range comparePairs {
+		m, found := pair.mapping[strings.ToUpper(sourceTable)]
+		if !found || pair.source != sourceInstance || pair.target != targetInstance || !strings.EqualFold(m.target, targetTable) {
+			continue
+		}
+		mapped := make([]string, 0, len(cols))
+		for _, col := range cols {
+			targetCol := col
+			for i, sourceCol := range m.sourceColumns {
+				if strings.EqualFold(sourceCol, col) {
+					targetCol = m.targetColumns[i]
+					break
+				}
+			}
+			mapped = append(mapped, targetCol)
+		}
+		return mapped
+	}
+	return cols
+}
+
+// tables returns the source tables to be compared, which are the configured tables or all checksummed tables of both
+// instances, except the target tables of table mappings.
+func (p comparePair) tables() []string {
+	if len(p.table) > 0 {
+		return p.table
+	}
+
+	var tables []string
+	found := make(map[string]bool)
+	mapped := make(map[string]bool) // target tables of table mappings
+	for _, m := range p.mapping {
+		mapped[strings.ToUpper(m.target)] = true
+	}
+	for _, instance := range []string{p.source, p.target} {
+		for _, result := range tableResults[instance] {
+			if instance == p.target && mapped[strings.ToUpper(result.table)] {
+				continue
+			}
+			if result.table != "" && !found[strings.ToUpper(result.table)] {
+				found[strings.ToUpper(result.table)] = true
+				tables = append(tables, result.table)
+			}
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return strings.ToUpper(tables[i]) < strings.ToUpper(tables[j]) })
+	return tables
+}
+
+// sideInfo describes the table result of one side of a compare pair.
+func sideInfo(instance, table string, result tableResult, found bool) string {
+	if !found {
+		return instance + "." + table + ": not found"
+	}
+	return fmt.Sprintf("%s.%s: %d rows", instance, result.table, result.numRows)
+}
+
+// compare compares the checksums of all tables of a compare pair and writes the compare result of each table.
+// It returns false if at least one table doesn't match.
+func (p comparePair) compare() bool {
+	match := true
+	for _, table := range p.tables() {
+		targetTable := p.targetTable(table)
+		source, sourceFound := findResult(p.source, table)
+		target, targetFound := findResult(p.target, targetTable)
+		var status string
+		switch {
+		case !sourceFound || !targetFound:
+			status = compareMissing
+		case source.checksum == target.checksum && source.numRows == target.numRows:
+			status = compareMatch
+		default:
+			status = compareMismatch
+		}
+		if status != compareMatch {
+			match = false
+		}
+
+		msg := fmt.Sprintf("%s.%s:%s (%s, %s)", p.name, table, status, sideInfo(p.source, table, source, sourceFound), sideInfo(p.target, targetTable, target, targetFound))
+		simplelog.Write(simplelog.MULTI, msg)
+	}
+	return match
+}
+
+// compareTables compiles the checksums of all instances of the compare pairs concurrently and compares the table pairs.
+// The table pairs aren't compared, if the checksum calculation was interrupted.
+func compareTables(ctx context.Context) int {
+	if len(comparePairs) == 0 {
+		simplelog.Write(simplelog.MULTI, mm022)
+		return md5Error
+	}
+
+	var instances []string
+	used := make(map[string]bool)
+	for _, pair := range comparePairs {
+		for _, instance := range []string{pair.source, pair.target} {
+			if !used[instance] {
+				used[instance] = true
+				instances = append(instances, instance)
+			}
+		}
+	}
+	rc := compileInstances(ctx, instances)
+	if rc&md5Interrupted != 0 {
+		return rc
+	}
+
+	for _, pair := range comparePairs {
+		if !pair.compare() {
+			rc |= md5Mismatch
+		}
+	}
+	return rc
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMappedColumns(t *testing.T) {
+	defer func(pairs []comparePair, command string) { comparePairs, pr.command = pairs, command }(comparePairs, pr.command)
+
+	comparePairs = []comparePair{{
+		name:   "migration",
+		source: "sqlite.source",
+		target: "sqlite.target",
+		mapping: map[string]tableMapping{"T1": {
+			target:        "T2",
+			sourceColumns: []string{"CODE", "ID", "NAME"},
+			targetColumns: []string{"CODE_NEW", "ID", "NAME_NEW"},
+		}},
+	}}
+	cols := []column{{"ID", "INTEGER", 1}, {"NAME", "TEXT", 2}, {"CODE", "TEXT", 3}, {"NOTE", "TEXT", 4}}
+	targetCols := []column{{"ID", "INTEGER", 1}, {"NAME_NEW", "TEXT", 2}, {"CODE_NEW", "TEXT", 3}}
+	names := func(cols []column) string {
+		var names []string
+		for _, col := range cols {
+			names = append(names, col.name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		command  string
+		instance string
+		table    string
+		cols     []column
+		exclude  []string
+		want     string
+	}{
+		{"", "sqlite.source", "T1", cols, nil, "ID,NAME,CODE,NOTE"},
+		{"", "sqlite.source", "T1", cols, []string{"NAME"}, "ID,CODE,NOTE"},
+		{compareCommand, "sqlite.source", "T1", cols, nil, "CODE,ID,NAME"},
+		{compareCommand, "sqlite.source", "T1", cols, []string{"NAME"}, "CODE,ID"},
+		{diffCommand, "sqlite.target", "T2", targetCols, nil, "CODE_NEW,ID,NAME_NEW"},
+		{diffCommand, "sqlite.target", "T2", targetCols, []string{"NAME%"}, "CODE_NEW,ID"},
+	}
+	for _, test := range tests {
+		pr.command = test.command
+		cfg := config{instance: test.instance, tableOpt: map[string]tableOptions{test.table: {exclude: test.exclude}}}
+		e := newEngine(&cfg, &sqliteDB{cfg: cfg})
+		selected, err := e.selectColumns(test.table, test.cols)
+		if err != nil {
+			t.Errorf("%q %s.%s: %v", test.command, test.instance, test.table, err)
+			continue
+		}
+		if got := names(selected); got != test.want {
+			t.Errorf("%q %s.%s excluding %v: got columns %s, want %s", test.command, test.instance, test.table, test.exclude, got, test.want)
+		}
+	}
+
+	pr.command = diffCommand
+	if got := sourceColumns("sqlite.target", "T2")["NAME_NEW"]; got != "NAME" {
+		t.Errorf("got source column %s of NAME_NEW, want NAME", got)
+	}
+	pr.command = ""
+	if got := sourceColumns("sqlite.target", "T2"); got != nil {
+		t.Errorf("got source columns %v without compare or diff command, want none", got)
+	}
+}
@@ -3,87 +3,356 @@ package main
 import (
 	"errors"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/sabitor/simplelog"
 	"github.com/spf13/viper"
+
+	"md5tabsum/checksum"
 )
 
 var (
 	instanceConfig = make(map[string]database) // store config file instances and their assigned configuration
 	instanceActive = make(map[string]bool)     // store active config file instances
+	comparePairs   []comparePair               // store the compare pairs of the Compare section
+)
+
+// validateCommand validates the config file, see setupEnv.
+const validateCommand string = "validate"
+
+// supported predefined DBMS names of the DBMS instance sections
+var supportedDbms = []string{"db2", "duckdb", "exasol", "file", "mysql", "mssql", "oracle", "postgresql", "sqlite"}
+
+// supported keywords of the config file sections, see instanceKeywords for the keywords of the DBMS instance sections
+var (
+	commonKeywords  = []string{"logfile", "passwordstore", "passwordstorekey", "parallelism", "hash", "aggregation", "timeout", "statementtimeout", "retries", "retrybackoff"}
+	tableKeywords   = []string{"buckets", "bucketkey", "columns", "excludecolumns", "filter"}
+	pairKeywords    = []string{"source", "target", "table", "mapping"}
+	mappingKeywords = []string{"table", "columns"}
+)
+
+// instanceKeywords returns the supported keywords of the instances of a predefined DBMS name.
+func instanceKeywords(dbms string) []string {
+	switch dbms {
+	case "file":
+		return []string{"active", "file", "format", "delimiter", "header", "columntypes"}
+	case "duckdb", "sqlite":
+		return []string{"active", "file", "schema", "table", "mode", "parallelism", "tableoptions", "query", "retries", "retrybackoff", "retryerrors"}
+	}
+	return slices.Concat([]string{"active", "host", "port", "user", "schema", "table", "mode", "parallelism", "tableoptions", "query", "retries", "retrybackoff", "retryerrors"}, dbmsKeywords(dbms))
+}
+
+// mandatoryKeywords returns the mandatory keywords of the active instances of a predefined DBMS name.
+// Hint: The Table keyword is mandatory for all DBMS, except for instances with queries only.
+func mandatoryKeywords(dbms string) []string {
+	switch dbms {
+	case "file", "duckdb", "sqlite":
+		return []string{"file"}
+	}
+	return slices.Concat([]string{"host", "port", "user", "schema"}, dbmsKeywords(dbms))
+}
+
+// dbmsKeywords returns the DBMS specific connection keywords of a predefined DBMS name.
+func dbmsKeywords(dbms string) []string {
+	switch dbms {
+	case "db2", "mssql", "postgresql":
+		return []string{"database"}
+	case "oracle":
+		return []string{"service"}
+	}
+	return nil
+}
+
+// configErrors collects the problems of a config file, thus all problems can be reported at once.
+type configErrors struct {
+	file     string
+	problems []string
+}
+
+// add adds a problem of a keyword of a config file section, e.g. oracle.prod. The keyword is empty for a problem of the section itself.
+func (c *configErrors) add(section, keyword, problem string) {
+	location := c.file + ": " + section
+	if keyword != "" {
+		location += ": " + strings.ToUpper(keyword[:1]) + keyword[1:]
+	}
+	c.problems = append(c.problems, location+": "+problem)
+}
+
+// checkKeywords adds a problem for every keyword of a config file section, which is not supported.
+func (c *configErrors) checkKeywords(section string, settings map[string]any, supported []string) {
+	for keyword := range settings {
+		if !slices.Contains(supported, keyword) {
+			c.add(section, keyword, mm051)
+		}
+	}
+}
+
+// err returns all problems sorted by their location, nil if there is no problem.
+func (c *configErrors) err() error {
+	if len(c.problems) == 0 {
+		return nil
+	}
+	sort.Strings(c.problems)
+	return errors.New(strings.Join(c.problems, "\n"))
+}
+
+// checksum calculation modes
+const (
+	serverMode string = "server" // the checksum is calculated by the DBMS
+	clientMode string = "client" // all rows are streamed and the checksum is calculated by md5tabsum
 )
 
 // collection of DBMS config attributes
 type config struct {
-	instance string
-	host     string
-	port     int
-	user     string
-	schema   string
-	table    []string
+	instance    string
+	host        string
+	port        int
+	user        string
+	schema      string
+	table       []string
+	mode        string
+	parallelism int                     // number of tables checksummed concurrently
+	tableOpt    map[string]tableOptions // table specific options of the Tableoptions section, the key is the upper case table name
+	queries     map[string]string       // SELECT statements of the Query section, the key is the query name
+	retry       retryPolicy             // retry policy of transient errors, the common retry policy if not configured
+}
+
+// findQuery returns the name and the SELECT statement of a query, query names are compared case-insensitive.
+func (c *config) findQuery(name string) (string, string, bool) {
+	for queryName, stmt := range c.queries {
+		if strings.EqualFold(queryName, name) {
+			return queryName, stmt, true
+		}
+	}
+	return "", "", false
+}
+
+// queryNames returns the names of all queries in alphabetical order.
+func (c *config) queryNames() []string {
+	names := make([]string, 0, len(c.queries))
+	for name := range c.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collection of table specific config attributes
+type tableOptions struct {
+	buckets   int      // number of buckets, the checksum is split into buckets if greater than 1
+	bucketKey string   // numeric key column of key range buckets, row hash buckets if not set
+	filter    string   // predicate which restricts the rows of the table, e.g. LOAD_DATE >= DATE '2026-01-01'
+	columns   []string // columns to be checksummed including placeholder characters (%), all columns if not set
+	exclude   []string // columns not to be checksummed including placeholder characters (%)
+}
+
+// options returns the table specific options of a table, table names are compared case-insensitive.
+func (c *config) options(table string) tableOptions {
+	return c.tableOpt[strings.ToUpper(table)]
+}
+
+// readTableOptions reads the table specific options of the Tableoptions section of an instance.
+func readTableOptions(instance string, v *viper.Viper, errs *configErrors) map[string]tableOptions {
+	tableOpt := make(map[string]tableOptions)
+	for table := range v.GetStringMap("tableoptions") {
+		section := instance + ".tableoptions." + table
+		cfgTable := v.Sub("tableoptions." + table)
+		if cfgTable == nil {
+			errs.add(section, "", mm052)
+			continue
+		}
+		errs.checkKeywords(section, cfgTable.AllSettings(), tableKeywords)
+		var opt tableOptions
+		if buckets := cfgTable.GetString("buckets"); buckets != "" {
+			n, err := strconv.Atoi(buckets)
+			if err != nil || n < 1 {
+				errs.add(section, "buckets", formatMsg(mm036, instance, table, buckets))
+			}
+			opt.buckets = n
+		}
+		opt.bucketKey = cfgTable.GetString("bucketkey")
+		opt.columns = splitList(cfgTable.GetString("columns"))
+		opt.exclude = splitList(cfgTable.GetString("excludecolumns"))
+		opt.filter = strings.TrimSpace(cfgTable.GetString("filter"))
+		if err := checkSQL(opt.filter); err != nil {
+			errs.add(section, "filter", formatMsg(mm039, instance, table, err.Error()))
+		}
+		tableOpt[strings.ToUpper(table)] = opt
+	}
+	return tableOpt
 }
 
-// setInstanceConfig sets the instance parameters according the parsed config file section
-func setInstanceConfig(instance string, v *viper.Viper) {
-	port, _ := strconv.Atoi(v.GetString("port"))
+// splitList splits a comma separated list of names, blanks and "\" characters are removed.
+func splitList(list string) []string {
+	list = strings.ReplaceAll(strings.ReplaceAll(list, " ", ""), "\\", "")
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// readMapping reads the table mappings of the Mapping section of a compare pair. Every source table is mapped to a target
+// table (Table, the same table name if not set) and optionally the source columns are mapped to the target columns
+// (Columns, a comma separated list of <source column>=<target column> or <column> if both names are equal).
+func readMapping(pairName string, cfgPair *viper.Viper, errs *configErrors) map[string]tableMapping {
+	mapping := make(map[string]tableMapping)
+	for source := range cfgPair.GetStringMap("mapping") {
+		section := "compare." + pairName + ".mapping." + source
+		m := tableMapping{target: source}
+		if cfgTable := cfgPair.Sub("mapping." + source); cfgTable != nil {
+			errs.checkKeywords(section, cfgTable.AllSettings(), mappingKeywords)
+			if target := strings.TrimSpace(cfgTable.GetString("table")); target != "" {
+				m.target = target
+			}
+			for _, col := range splitList(cfgTable.GetString("columns")) {
+				sourceCol, targetCol, found := strings.Cut(col, "=")
+				if !found {
+					targetCol = sourceCol
+				}
+				if sourceCol == "" || targetCol == "" {
+					errs.add(section, "columns", formatMsg(mm041, pairName, source, col))
+					continue
+				}
+				m.sourceColumns = append(m.sourceColumns, sourceCol)
+				m.targetColumns = append(m.targetColumns, targetCol)
+			}
+		}
+		mapping[strings.ToUpper(source)] = m
+	}
+	return mapping
+}
+
+// readQueries reads the SELECT statements of the Query section of an instance.
+// A trailing statement separator is removed, because a query is embedded as subquery into the checksum statements.
+func readQueries(instance string, v *viper.Viper, errs *configErrors) map[string]string {
+	queries := make(map[string]string)
+	for name, query := range v.GetStringMapString("query") {
+		stmt := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+		err := checkSQL(stmt)
+		// the first keyword may be followed by any white space, e.g. a line break, or a parenthesis
+		keywords := strings.FieldsFunc(strings.ToLower(stmt), func(r rune) bool { return unicode.IsSpace(r) || r == '(' })
+		if err == nil && (len(keywords) == 0 || keywords[0] != "select" && keywords[0] != "with") {
+			err = errors.New("not a SELECT statement")
+		}
+		if err != nil {
+			errs.add(instance+".query", name, formatMsg(mm040, instance, name, err.Error()))
+		}
+		queries[name] = stmt
+	}
+	return queries
+}
+
+// checkSQL checks that a SQL fragment, e.g. a table filter or a query, can be embedded in any statement.
+// Thus, string literals and quoted identifiers have to be terminated, parentheses have to be balanced and neither
+// statement separators nor comments are allowed.
+func checkSQL(filter string) error {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(filter) && filter[i+1] == quote {
+				// a backslash escapes a quote in MySQL only, thus the end of the quoted part would depend on the DBMS
+				return errors.New("backslash escaped quote at position " + strconv.Itoa(i+1))
+			}
+			if c == quote {
+				quote = 0 // an escaped quote ('' or "") terminates and starts a quoted part again
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth < 0 {
+				return errors.New("unbalanced parenthesis at position " + strconv.Itoa(i+1))
+			}
+		case c == ';':
+			return errors.New("statement separator at position " + strconv.Itoa(i+1))
+		case strings.HasPrefix(filter[i:], "--"), strings.HasPrefix(filter[i:], "/*"), strings.HasPrefix(filter[i:], "*/"):
+			return errors.New("comment at position " + strconv.Itoa(i+1))
+		}
+	}
+	switch {
+	case quote != 0:
+		return errors.New("unterminated " + string(quote) + " quote")
+	case depth > 0:
+		return errors.New("missing closing parenthesis")
+	}
+	return nil
+}
+
+// setInstanceConfig sets the instance parameters according the parsed config file section. The numeric parameters,
+// the mode, the table options and the queries of the config have already been validated by readInstance.
+func setInstanceConfig(v *viper.Viper, cfg config) {
+	instance := cfg.instance
 	allTables := strings.Split(strings.ReplaceAll(strings.ReplaceAll(v.GetString("table"), " ", ""), "\\", ""), ",") // replace " " and "\"" by ""
+	if v.GetString("table") == "" && len(cfg.queries) > 0 {
+		// the instance checksums queries only
+		allTables = nil
+	}
+	for _, pattern := range strings.Split(v.GetString("retryerrors"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			cfg.retry.errors = append(cfg.retry.errors, pattern)
+		}
+	}
+	cfg.host = v.GetString("host")
+	cfg.user = v.GetString("user")
+	cfg.schema = v.GetString("schema")
+	cfg.table = allTables
 	cfgSectionParts := strings.Split(instance, ".")
 	switch cfgSectionParts[0] {
 	case "exasol":
 		instanceConfig[instance] = &exasolDB{
-			cfg: config{
-				instance: instance,
-				host:     v.GetString("host"),
-				port:     port,
-				user:     v.GetString("user"),
-				schema:   v.GetString("schema"),
-				table:    allTables},
+			cfg: cfg,
 		}
 	case "oracle":
 		instanceConfig[instance] = &oracleDB{
-			cfg: config{
-				instance: instance,
-				host:     v.GetString("host"),
-				port:     port,
-				user:     v.GetString("user"),
-				schema:   v.GetString("schema"),
-				table:    allTables},
+			cfg: cfg,
 			srv: v.GetString("service"),
 		}
 	case "mysql":
 		instanceConfig[instance] = &mysqlDB{
-			cfg: config{
-				instance: instance,
-				host:     v.GetString("host"),
-				port:     port,
-				user:     v.GetString("user"),
-				schema:   v.GetString("schema"),
-				table:    allTables},
+			cfg: cfg,
 		}
 	case "postgresql":
 		instanceConfig[instance] = &postgresqlDB{
-			cfg: config{
-				instance: instance,
-				host:     v.GetString("host"),
-				port:     port,
-				user:     v.GetString("user"),
-				schema:   v.GetString("schema"),
-				table:    allTables},
-			db: v.GetString("database"),
+			cfg: cfg,
+			db:  v.GetString("database"),
 		}
 	case "mssql":
 		instanceConfig[instance] = &mssqlDB{
-			cfg: config{
-				instance: instance,
-				host:     v.GetString("host"),
-				port:     port,
-				user:     v.GetString("user"),
-				schema:   v.GetString("schema"),
-				table:    allTables,
-			},
-			db: v.GetString("database"),
+			cfg: cfg,
+			db:  v.GetString("database"),
+		}
+	case "db2":
+		instanceConfig[instance] = &db2DB{
+			cfg: cfg,
+			db:  v.GetString("database"),
+		}
+	case "duckdb":
+		instanceConfig[instance] = &duckdbDB{
+			cfg:  cfg,
+			path: v.GetString("file"),
+		}
+	case "file":
+		instanceConfig[instance] = &fileDB{
+			cfg:         cfg,
+			path:        v.GetString("file"),
+			fileFormat:  v.GetString("format"),
+			delimiter:   v.GetString("delimiter"),
+			header:      v.GetString("header") != "0",
+			columnTypes: v.GetString("columntypes"),
+		}
+	case "sqlite":
+		instanceConfig[instance] = &sqliteDB{
+			cfg:  cfg,
+			path: v.GetString("file"),
 		}
 	// CHECK: Add support for other DBMS
 	default:
@@ -91,7 +360,8 @@ func setInstanceConfig(instance string, v *viper.Viper) {
 	}
 }
 
-// setupEnv reads the config file and sets the instance config for all active instances
+// setupEnv reads the config file and sets the instance config for all active instances.
+// The config file is validated completely, all problems are returned as one error, see configErrors.
 func setupEnv(cfg string) error {
 	var err error
 
@@ -101,42 +371,228 @@ func setupEnv(cfg string) error {
 	if err = viper.ReadInConfig(); err != nil {
 		return err
 	}
+	errs := &configErrors{file: cfg}
 
 	// read common config parameters
+	for keyword, value := range viper.AllSettings() {
+		_, isSection := value.(map[string]any)
+		switch {
+		case slices.Contains(commonKeywords, keyword) && !isSection:
+		case (slices.Contains(supportedDbms, keyword) || keyword == "compare") && isSection:
+		case isSection:
+			errs.add(keyword, "", mm053)
+		default:
+			errs.add("common", keyword, mm051)
+		}
+	}
+
 	logFile := viper.GetString("Logfile")
 	if logFile == "" {
-		return errors.New(mm013)
+		errs.add("common", "logfile", mm013)
+	} else {
+		simplelog.SetupLog(logFile, true)
 	}
-	simplelog.SetupLog(logFile, true)
 
 	passwordStoreFile = viper.GetString("Passwordstore")
 	if passwordStoreFile == "" {
-		return errors.New(mm014)
+		errs.add("common", "passwordstore", mm014)
 	}
 
 	passwordStoreKeyFile = viper.GetString("Passwordstorekey")
 	if passwordStoreKeyFile == "" {
-		return errors.New(mm015)
+		errs.add("common", "passwordstorekey", mm015)
+	}
+
+	if parallelism := viper.GetString("Parallelism"); parallelism != "" {
+		if n, err := strconv.Atoi(parallelism); err != nil || n < 1 {
+			errs.add("common", "parallelism", formatMsg(mm038, parallelism))
+		} else {
+			sessionSlots = make(chan struct{}, n)
+		}
+	}
+
+	// the hash algorithm of the -hash option overrides the Hash parameter
+	hash := viper.GetString("Hash")
+	if pr.hash != "" {
+		hash = pr.hash
+	}
+	if hash != "" {
+		if hashAlgorithm, err = checksum.ParseHash(hash); err != nil {
+			errs.add("common", "hash", formatMsg(mm043, hash))
+		}
+	}
+
+	if version := viper.GetString("Aggregation"); version != "" {
+		if aggregation, err = checksum.ParseVersion(version); err != nil {
+			errs.add("common", "aggregation", formatMsg(mm045, version))
+		}
+	}
+
+	if timeout := viper.GetString("Timeout"); timeout != "" {
+		if runTimeout, err = time.ParseDuration(timeout); err != nil || runTimeout <= 0 {
+			errs.add("common", "timeout", formatMsg(mm060, timeout))
+		}
+	}
+
+	if timeout := viper.GetString("Statementtimeout"); timeout != "" {
+		if statementTimeout, err = time.ParseDuration(timeout); err != nil || statementTimeout <= 0 {
+			errs.add("common", "statementtimeout", formatMsg(mm061, timeout))
+		}
+	}
+
+	if retries := viper.GetString("Retries"); retries != "" {
+		if n, err := strconv.Atoi(retries); err != nil || n < 0 {
+			errs.add("common", "retries", formatMsg(mm063, retries))
+		} else {
+			commonRetry.retries = n
+		}
+	}
+
+	if backoff := viper.GetString("Retrybackoff"); backoff != "" {
+		if d, err := time.ParseDuration(backoff); err != nil || d <= 0 {
+			errs.add("common", "retrybackoff", formatMsg(mm065, backoff))
+		} else {
+			commonRetry.backoff = d
+		}
 	}
 
 	// read DBMS instance config parameters
-	supportedDbms := []string{"exasol", "mysql", "mssql", "oracle", "postgresql"}
-	instanceKeywords := map[string]struct{}{"active": {}, "host": {}, "port": {}, "user": {}, "database": {}, "schema": {}, "service": {}, "table": {}}
 	for _, v := range supportedDbms {
-		cfgFirstLevelKey := viper.GetStringMapString(v) // all cfg instances (instance1, instance2, ...) are assigned to a DBMS name (mysql, oracle, ...)
+		cfgFirstLevelKey := viper.GetStringMap(v) // all cfg instances (instance1, instance2, ...) are assigned to a DBMS name (mysql, oracle, ...)
 		for k := range cfgFirstLevelKey {
-			if _, isInstanceID := instanceKeywords[k]; isInstanceID {
-				return errors.New(formatMsg(mm017, v))
+			if slices.Contains(instanceKeywords(v), k) {
+				errs.add(v, "", formatMsg(mm017, v))
+				continue
 			}
 			dbmsInstance := v + "." + k // e.g. mysql.instance1
-			if cfgInstance := viper.Sub(dbmsInstance); cfgInstance != nil {
-				if cfgInstance.GetString("active") == "1" {
-					instanceActive[dbmsInstance] = true
+			cfgInstance := viper.Sub(dbmsInstance)
+			if cfgInstance == nil {
+				errs.add(dbmsInstance, "", mm052)
+				continue
+			}
+			readInstance(v, dbmsInstance, cfgInstance, errs)
+		}
+	}
+
+	// read compare pairs
+	for k := range viper.GetStringMap("compare") {
+		section := "compare." + k
+		cfgPair := viper.Sub(section)
+		if cfgPair == nil {
+			errs.add(section, "", formatMsg(mm020, k))
+			continue
+		}
+		errs.checkKeywords(section, cfgPair.AllSettings(), pairKeywords)
+		pair := comparePair{
+			name:   k,
+			source: strings.ToLower(cfgPair.GetString("source")),
+			target: strings.ToLower(cfgPair.GetString("target")),
+		}
+		if pair.source == "" || pair.target == "" {
+			errs.add(section, "", formatMsg(mm020, k))
+			continue
+		}
+		// the instances of a compare pair are required by the compare command only, other runs can deactivate them
+		for _, keyword := range []string{"source", "target"} {
+			if instance := strings.ToLower(cfgPair.GetString(keyword)); !instanceActive[instance] && (pr.command == compareCommand || pr.command == validateCommand) {
+				errs.add(section, keyword, formatMsg(mm021, k, instance))
+			}
+		}
+		if tables := strings.ReplaceAll(strings.ReplaceAll(cfgPair.GetString("table"), " ", ""), "\\", ""); tables != "" {
+			pair.table = strings.Split(tables, ",")
+		}
+		pair.mapping = readMapping(k, cfgPair, errs)
+		comparePairs = append(comparePairs, pair)
+	}
+	sort.Slice(comparePairs, func(i, j int) bool { return comparePairs[i].name < comparePairs[j].name })
+
+	// a table can only have one column order, even if it is mapped by several compare pairs
+	columnOrder := make(map[string]string)
+	for _, pair := range comparePairs {
+		for source, m := range pair.mapping {
+			for _, side := range [][2]string{{pair.source + "." + source, strings.Join(m.sourceColumns, ",")}, {pair.target + "." + strings.ToUpper(m.target), strings.Join(m.targetColumns, ",")}} {
+				if order, found := columnOrder[side[0]]; found && !strings.EqualFold(order, side[1]) {
+					errs.add("compare."+pair.name, "mapping", formatMsg(mm042, side[0]))
 				}
-				setInstanceConfig(dbmsInstance, cfgInstance)
+				columnOrder[side[0]] = side[1]
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+// readInstance validates the keywords of an instance section and sets its instance config.
+// The mandatory keywords are checked for active instances only.
+func readInstance(dbms, instance string, v *viper.Viper, errs *configErrors) {
+	settings := v.AllSettings()
+	errs.checkKeywords(instance, settings, instanceKeywords(dbms))
+
+	switch active := v.GetString("active"); active {
+	case "1":
+		instanceActive[instance] = true
+	case "", "0":
+	default:
+		errs.add(instance, "active", formatMsg(mm054, active))
+	}
+	if instanceActive[instance] {
+		for _, keyword := range mandatoryKeywords(dbms) {
+			if v.GetString(keyword) == "" {
+				errs.add(instance, keyword, formatMsg(mm055, dbms))
 			}
 		}
+		if dbms != "file" && v.GetString("table") == "" && len(v.GetStringMap("query")) == 0 {
+			errs.add(instance, "table", formatMsg(mm055, dbms))
+		}
+	}
+	// the validated parameters are set, invalid parameters keep their defaults
+	cfg := config{instance: instance, mode: serverMode, parallelism: 1, retry: commonRetry}
+	if port := v.GetString("port"); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			errs.add(instance, "port", formatMsg(mm056, port))
+		} else {
+			cfg.port = n
+		}
+	}
+	switch mode := strings.ToLower(v.GetString("mode")); mode {
+	case "":
+	case serverMode, clientMode:
+		cfg.mode = mode
+	default:
+		errs.add(instance, "mode", formatMsg(mm019, instance, v.GetString("mode")))
+	}
+	if parallelism := v.GetString("parallelism"); parallelism != "" {
+		if n, err := strconv.Atoi(parallelism); err != nil || n < 1 {
+			errs.add(instance, "parallelism", formatMsg(mm037, instance, parallelism))
+		} else {
+			cfg.parallelism = n
+		}
+	}
+	if retries := v.GetString("retries"); retries != "" {
+		if n, err := strconv.Atoi(retries); err != nil || n < 0 {
+			errs.add(instance, "retries", formatMsg(mm064, instance, retries))
+		} else {
+			cfg.retry.retries = n
+		}
+	}
+	if backoff := v.GetString("retrybackoff"); backoff != "" {
+		if d, err := time.ParseDuration(backoff); err != nil || d <= 0 {
+			errs.add(instance, "retrybackoff", formatMsg(mm066, instance, backoff))
+		} else {
+			cfg.retry.backoff = d
+		}
+	}
+	if format := strings.ToLower(v.GetString("format")); format != "" && format != csvFormat && format != parquetFormat {
+		errs.add(instance, "format", formatMsg(mm057, v.GetString("format")))
+	}
+	if header := v.GetString("header"); header != "" && header != "0" && header != "1" {
+		errs.add(instance, "header", formatMsg(mm058, header))
+	}
+	if _, err := (&fileDB{columnTypes: v.GetString("columntypes")}).columns(); err != nil {
+		errs.add(instance, "columntypes", err.Error())
 	}
 
-	return err
+	cfg.tableOpt = readTableOptions(instance, v, errs)
+	cfg.queries = readQueries(instance, v, errs)
+	setInstanceConfig(v, cfg)
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestReadQueries(t *testing.T) {
+	tests := map[string]bool{
+		"select * from T":                          true,
+		"SELECT\n  ID,\n  CODE\nFROM T;":           true,
+		"select\tID from T":                        true,
+		"with\nx as (select 1 ID) select * from x": true,
+		"select(1)":                                true,
+		"delete from T":                            false,
+		"selectx from T":                           false,
+		"":                                         false,
+	}
+	for query, ok := range tests {
+		v := viper.New()
+		v.Set("query", map[string]any{"q": query})
+		errs := configErrors{file: "test.cfg"}
+		queries := readQueries("sqlite.test", v, &errs)
+		if got := errs.err() == nil; got != ok {
+			t.Errorf("query %q: got valid %t, want %t (%v)", query, got, ok, errs.err())
+		}
+		if want := strings.TrimSuffix(strings.TrimSpace(query), ";"); queries["q"] != want {
+			t.Errorf("query %q: got statement %q, want %q", query, queries["q"], want)
+		}
+	}
+}
+
+func TestReadInstanceNumbers(t *testing.T) {
+	v := viper.New()
+	v.Set("port", "abc")
+	v.Set("parallelism", "4")
+	v.Set("retries", "x")
+	v.Set("mode", "CLIENT")
+	errs := configErrors{file: "test.cfg"}
+	readInstance("mysql", "mysql.test", v, &errs)
+	defer delete(instanceConfig, "mysql.test")
+	if errs.err() == nil {
+		t.Fatal("got no error for port abc and retries x")
+	}
+	db, ok := instanceConfig["mysql.test"].(*mysqlDB)
+	if !ok {
+		t.Fatalf("got instance %T, want *mysqlDB", instanceConfig["mysql.test"])
+	}
+	if db.cfg.port != 0 || db.cfg.parallelism != 4 || db.cfg.mode != clientMode || db.cfg.retry.retries != commonRetry.retries {
+		t.Errorf("got port %d, parallelism %d, mode %q, retries %d", db.cfg.port, db.cfg.parallelism, db.cfg.mode, db.cfg.retry.retries)
+	}
+}
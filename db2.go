@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+type db2DB struct {
+	cfg config
+	db  string // Db2 specific
+}
+
+func (d *db2DB) instance() string {
+	return d.cfg.instance
+}
+
+func (d *db2DB) host() string {
+	return d.cfg.host
+}
+
+func (d *db2DB) port() int {
+	return d.cfg.port
+}
+
+func (d *db2DB) user() string {
+	return d.cfg.user
+}
+
+func (d *db2DB) schema() string {
+	return d.cfg.schema
+}
+
+func (d *db2DB) table() []string {
+	return d.cfg.table
+}
+
+func (d *db2DB) database() string {
+	return d.db
+}
+
+func (d *db2DB) logPrefix() string {
+	return "[" + d.instance() + "] -"
+}
+
+// ----------------------------------------------------------------------------
+func (d *db2DB) openDB(ctx context.Context, password string) (*sql.DB, error) {
+	tableFilter := strings.Join(d.table(), ", ")
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, d.logPrefix(), "Profile parameter:", "Host:"+d.host()+",", "Port:"+strconv.Itoa(d.port())+",", "Database:"+d.database()+",", "User:"+d.user()+",", "Schema:"+d.schema()+",", "Table:"+tableFilter)
+	dsn := fmt.Sprintf("HOSTNAME=%s;PORT=%d;DATABASE=%s;UID=%s;PWD=%s", d.host(), d.port(), d.database(), d.user(), password)
+	// Hint: The driver is only available if md5tabsum was built with the 'db2' build tag (see db2_driver.go).
+	db, err := connectDB(ctx, "go_ibm_db", dsn)
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, d.logPrefix(), err.Error())
+		return db, err
+	}
+	return db, err
+}
+
+func (d *db2DB) closeDB(db *sql.DB) error {
+	return db.Close()
+}
+
+func (d *db2DB) queryDB(ctx context.Context, db *sql.DB) error {
+	return d.checksumEngine().run(ctx, db)
+}
+
+func (d *db2DB) checksumEngine() *engine {
+	return newEngine(&d.cfg, d)
+}
+
+// ----------------------------------------------------------------------------
+// dialect
+
+func (d *db2DB) sessionStmt() []string {
+	return nil
+}
+
+func (d *db2DB) versionStmt() string {
+	return "select GETVARIABLE('SYSIBM.VERSION'), CURRENT USER from SYSIBM.SYSDUMMY1"
+}
+
+func (d *db2DB) transientErrors() []string {
+	// SQL0911N deadlock or lock timeout, SQL30081N communication error, SQL30108N client reroute and SQL1224N agent terminated
+	return []string{"SQL0911N", "SQL30081N", "SQL30108N", "SQL1224N"}
+}
+
+func (d *db2DB) tableStmt(schema, table string) (string, []any) {
+	return "select TABNAME from SYSCAT.TABLES where TABSCHEMA=? and TABNAME like ? and TYPE='T'", []any{strings.ToUpper(schema), strings.ToUpper(table)}
+}
+
+func (d *db2DB) columnStmt(schema, table string) (string, []any) {
+	return "select COLNAME, TYPENAME || '(' || LENGTH || ',' || SCALE || ')' as DATA_TYPE, COLNO+1 from SYSCAT.COLUMNS where TABSCHEMA=? and TABNAME=? order by COLNO asc", []any{strings.ToUpper(schema), strings.ToUpper(table)}
+}
+
+func (d *db2DB) structureStmt(schema, table string) (string, []any) {
+	// Hint: The LENGTH column is the length of character data types and the precision of decimal data types.
+	return "select COLNAME, TYPENAME, LENGTH, LENGTH, SCALE, COLNO+1, case NULLS when 'Y' then 1 else 0 end, coalesce(KEYSEQ, 0) " +
+		"from SYSCAT.COLUMNS where TABSCHEMA=? and TABNAME=? order by COLNO asc", []any{strings.ToUpper(schema), strings.ToUpper(table)}
+}
+
+func (d *db2DB) quoteIdent(ident string) string {
+	return "\"" + strings.ReplaceAll(ident, "\"", "\"\"") + "\""
+}
+
+func (d *db2DB) quoteLiteral(literal string) string {
+	return "'" + strings.ReplaceAll(literal, "'", "''") + "'"
+}
+
+func (d *db2DB) timestampLiteral(value time.Time, class typeClass) string {
+	// Hint: The Db2 specific format is independent of the date and timestamp formats of the session.
+	if class == dateType {
+		return "date('" + value.Format("2006-01-02") + "')"
+	}
+	return "timestamp('" + value.Format("2006-01-02-15.04.05.000000") + "')"
+}
+
+func (d *db2DB) typeClass(columnType string) typeClass {
+	columnType = strings.ToUpper(columnType)
+	switch {
+	case strings.Contains(columnType, "CHAR"):
+		return charType
+	case strings.Contains(columnType, "TIMESTAMP"):
+		return timestampType
+	case strings.Contains(columnType, "DATE"):
+		return dateType
+	case strings.Contains(columnType, "DECFLOAT"), strings.Contains(columnType, "DECIMAL"), strings.Contains(columnType, "NUMERIC"):
+		return decimalType
+	case strings.Contains(columnType, "DOUBLE"), strings.Contains(columnType, "REAL"), strings.Contains(columnType, "FLOAT"):
+		return floatType
+	case strings.Contains(columnType, "BOOLEAN"):
+		return booleanType
+	default:
+		return otherType
+	}
+}
+
+// trimDecfloatExpr converts a DECFLOAT expression into a string without trailing zeros of the fractional part.
+// Hint: The string representation of a DECFLOAT keeps the leading 0 of numbers between -1 and 1, e.g. 0.90 becomes '0.90'.
+func (d *db2DB) trimDecfloatExpr(decfloat string) string {
+	return "case when locate('.', varchar(" + decfloat + ")) > 0 then rtrim(rtrim(varchar(" + decfloat + "), '0'), '.') else varchar(" + decfloat + ") end"
+}
+
+func (d *db2DB) canonicalExpr(column string, class typeClass) string {
+	switch class {
+	case charType:
+		// calculate the hash of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
+		// Hint: rtrim removes the blank padding of CHAR columns.
+		return "case when " + column + " is NULL then 'null' else " + d.hashExpr("rtrim("+column+")") + " end"
+	case dateType:
+		return "coalesce(varchar_format(" + column + ", 'YYYY-MM-DD') || ' 00:00:00.000000', 'null')"
+	case timestampType:
+		return "coalesce(varchar_format(" + column + ", 'YYYY-MM-DD HH24:MI:SS.FF6'), 'null')"
+	case decimalType:
+		return "case when " + column + " is NULL then 'null' else " + d.trimDecfloatExpr("cast("+column+" as decfloat(34))") + " end"
+	case floatType:
+		// Hint: varchar of a DOUBLE returns the scientific notation, e.g. 4.01234E1, thus it is converted into a DECFLOAT with 16 digits first.
+		return "case when " + column + " is NULL then 'null' else " + d.trimDecfloatExpr("cast("+column+" as decfloat(16))") + " end"
+	case booleanType:
+		return "case when " + column + " is NULL then 'null' when " + column + " then '1' else '0' end"
+	default:
+		return "coalesce(varchar(" + column + "), 'null')"
+	}
+}
+
+func (d *db2DB) concatExpr(columns []string) string {
+	return strings.Join(columns, " || ")
+}
+
+// hashExpr returns the hash (lowercase hex digits) of a string expression, the hash functions of Db2 return binary strings.
+func (d *db2DB) hashExpr(expr string) string {
+	switch hashAlgorithm {
+	case checksum.SHA1:
+		return "lower(hex(hash_sha1(" + expr + ")))"
+	case checksum.SHA256:
+		return "lower(hex(hash_sha256(" + expr + ")))"
+	default:
+		return "lower(hex(hash_md5(" + expr + ")))"
+	}
+}
+
+func (d *db2DB) rowHashExpr(row string) string {
+	return d.hashExpr(row)
+}
+
+// hexToIntExpr converts 8 hex digits of a hash expression, starting at the given position, into a BIGINT.
+// Hint: Db2 has no built-in function to convert hex digits into a number, thus it is computed digit by digit.
+func (d *db2DB) hexToIntExpr(hash string, start int) string {
+	digits := make([]string, 0, 8)
+	for i := 0; i < 8; i++ {
+		digits = append(digits, "bigint(locate(substr("+hash+", "+strconv.Itoa(start+i)+", 1), '0123456789abcdef') - 1) * "+strconv.FormatInt(1<<(4*(7-i)), 10))
+	}
+	return "(" + strings.Join(digits, " + ") + ")"
+}
+
+func (d *db2DB) partSumExpr(start int) string {
+	return "varchar(sum(" + d.hexToIntExpr("t.ROWHASH", start) + "))"
+}
+
+// Hint: The division of BIGINT values is an integer division.
+func (d *db2DB) partXorExpr(start int) string {
+	div := func(a, b string) string { return "(" + a + " / " + b + ")" }
+	mod := func(a, b string) string { return "mod(" + a + ", " + b + ")" }
+	return "varchar(" + bitXorExpr(d.hexToIntExpr("t.ROWHASH", start), div, mod) + ")"
+}
+
+func (d *db2DB) bucketExpr(hash string, buckets int) string {
+	return "mod(" + d.hexToIntExpr(hash, 1) + ", " + strconv.Itoa(buckets) + ")"
+}
+
+func (d *db2DB) checksumExpr() string {
+	return "count(1) NUMROWS, coalesce(" + d.hashExpr(strings.Join(partSumExprs(d), " || ")) + ", '" + hashAlgorithm.Empty() + "') CHECKSUM"
+}
@@ -0,0 +1,8 @@
+//go:build db2
+
+package main
+
+// The Db2 driver requires the IBM Db2 CLI driver (cgo), thus it is only linked if md5tabsum is built with the 'db2' build tag.
+import (
+	_ "github.com/ibmdb/go_ibm_db"
+)
@@ -0,0 +1,10 @@
+//go:build db2
+
+package main
+
+// The Db2 test requires a Db2 database, which is specified by the CLI connection string of the MD5TABSUM_DB2_DSN
+// environment variable, e.g. HOSTNAME=localhost;PORT=50000;DATABASE=testdb;UID=db2inst1;PWD=secret
+// The table T1 is created in the current schema of the user and dropped afterwards.
+func init() {
+	t1Instances["db2.test"] = t1Instance{driver: "go_ibm_db", dsnEnv: "MD5TABSUM_DB2_DSN", schemaStmt: "values current schema", database: func(cfg config) database { return &db2DB{cfg: cfg} }}
+}
@@ -1,15 +1,134 @@
-package main
-
-import (
-	"database/sql"
-)
-
-// Database interface
-type database interface {
-	// openDB implements the DBMS specific open function.
-	openDB(string) (*sql.DB, error)
-	// closeDB implements the DBMS specific close function.
-	closeDB(*sql.DB) error
-	// queryDB implements any DBMS specific function using a handle returned from openDB() to work with the underlying database.
-	queryDB(*sql.DB) error
-}
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"md5tabsum/checksum"
+)
+
+// Database interface
+type database interface {
+	// openDB implements the DBMS specific open function.
+	openDB(context.Context, string) (*sql.DB, error)
+	// closeDB implements the DBMS specific close function.
+	closeDB(*sql.DB) error
+	// queryDB implements any DBMS specific function using a handle returned from openDB() to work with the underlying database.
+	// All statements are canceled, if the context is canceled.
+	queryDB(context.Context, *sql.DB) error
+}
+
+// connectDB opens a database handle of a driver and verifies the connection to the DBMS within the statement timeout.
+func connectDB(ctx context.Context, driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return db, err
+	}
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// typeClass groups DBMS specific column data types which share the same canonical string representation.
+// The canonical strings are specified by the reference implementation in package checksum.
+type typeClass = checksum.Class
+
+const (
+	otherType     = checksum.Other     // any other data type, converted by a plain cast into a string
+	charType      = checksum.Char      // character data types, represented by the hash of the right trimmed value
+	decimalType   = checksum.Decimal   // exact numeric data types, represented without trailing zeros
+	floatType     = checksum.Float     // approximate numeric data types
+	dateType      = checksum.Date      // date data types without a time part
+	timestampType = checksum.Timestamp // time and timestamp data types, represented as 'YYYY-MM-DD HH24:MI:SS.FF6'
+	booleanType   = checksum.Boolean   // boolean data types, represented as 1 or 0
+)
+
+// Dialect interface
+// A dialect provides all DBMS specific SQL snippets, which are required by the engine to compile a table checksum.
+type dialect interface {
+	// sessionStmt returns the statements to be executed once at the beginning of a database session.
+	sessionStmt() []string
+	// versionStmt returns the statement which queries the version of the DBMS and the authenticated user (NULL if the DBMS has no users).
+	versionStmt() string
+	// transientErrors returns the error message patterns (case-insensitive) of the transient errors of the DBMS, e.g. deadlocks
+	// and lost connections, which are retried according to the retry policy of the instance.
+	transientErrors() []string
+	// tableStmt returns the statement (and its arguments) to find all tables of a schema matching a table filter including placeholders (e.g. %).
+	tableStmt(schema, table string) (string, []any)
+	// columnStmt returns the statement (and its arguments) to query name, data type and ordinal position of all columns of a table.
+	columnStmt(schema, table string) (string, []any)
+	// structureStmt returns the statement (and its arguments) to query name, data type, character length, numeric precision,
+	// numeric scale, ordinal position, nullability (1 or 0) and primary key position (0 if not part of the primary key) of all columns of a table.
+	structureStmt(schema, table string) (string, []any)
+	// quoteIdent quotes a table or column identifier.
+	quoteIdent(string) string
+	// quoteLiteral quotes a string literal.
+	quoteLiteral(string) string
+	// timestampLiteral returns the literal of a date (dateType) or timestamp value, which doesn't depend on the date and
+	// timestamp formats of the session.
+	timestampLiteral(value time.Time, class typeClass) string
+	// typeClass maps a DBMS specific column data type to its type class.
+	typeClass(columnType string) typeClass
+	// canonicalExpr returns the expression which converts a column of the given type class into its canonical string ('null' for NULL values).
+	canonicalExpr(column string, class typeClass) string
+	// concatExpr returns the expression which concatenates all canonical column expressions of a row.
+	concatExpr([]string) string
+	// rowHashExpr returns the expression which compiles the hash (e.g. 32 lowercase hex digits of MD5) of a concatenated row.
+	rowHashExpr(string) string
+	// partSumExpr returns the sum of the 8 hex digit part of the ROWHASH column, starting at the given position, as decimal string.
+	partSumExpr(start int) string
+	// partXorExpr returns the bitwise XOR of the 8 hex digit part of the ROWHASH column of all rows, starting at the given position, as decimal string.
+	partXorExpr(start int) string
+	// bucketExpr returns the bucket number (0 to buckets-1) of a row, the remainder of the first 8 hex digits of a hash expression divided by the number of buckets.
+	bucketExpr(hash string, buckets int) string
+	// checksumExpr returns the select list which aggregates the ROWHASH column of all rows into NUMROWS and CHECKSUM.
+	checksumExpr() string
+}
+
+// partSumExprs returns the sum expressions of the 8 hex digit parts of the ROWHASH column, e.g. four parts of MD5.
+func partSumExprs(dia dialect) []string {
+	sums := make([]string, 0, hashAlgorithm.Parts())
+	for i := range hashAlgorithm.Parts() {
+		sums = append(sums, dia.partSumExpr(i*8+1))
+	}
+	return sums
+}
+
+// partXorExprs returns the XOR expressions of the 8 hex digit parts of the ROWHASH column.
+func partXorExprs(dia dialect) []string {
+	xors := make([]string, 0, hashAlgorithm.Parts())
+	for i := range hashAlgorithm.Parts() {
+		xors = append(xors, dia.partXorExpr(i*8+1))
+	}
+	return xors
+}
+
+// bitXorExpr returns the bitwise XOR of a 32 bit integer expression of all rows for DBMS without a bitwise XOR aggregate
+// function: each bit of the XOR is the parity of the number of rows, whose value has the bit set.
+// The functions div and mod build the integer division and the remainder of two integer expressions.
+func bitXorExpr(expr string, div, mod func(a, b string) string) string {
+	terms := make([]string, 0, 32)
+	for i := range 32 {
+		weight := strconv.FormatInt(1<<i, 10)
+		terms = append(terms, mod("sum("+mod(div(expr, weight), "2")+")", "2")+" * "+weight)
+	}
+	return strings.Join(terms, " + ")
+}
+
+// infoSchemaStructureStmt returns the structure statement of DBMS providing the INFORMATION_SCHEMA views of the SQL standard.
+// The data type expression can refine the DATA_TYPE column, schemaArg and tableArg are the placeholders of the schema and table arguments.
+func infoSchemaStructureStmt(dataType, schemaArg, tableArg string) string {
+	return "select c.COLUMN_NAME, " + dataType + ", c.CHARACTER_MAXIMUM_LENGTH, c.NUMERIC_PRECISION, c.NUMERIC_SCALE, c.ORDINAL_POSITION, " +
+		"case c.IS_NULLABLE when 'YES' then 1 else 0 end, " +
+		"coalesce((select k.ORDINAL_POSITION from INFORMATION_SCHEMA.TABLE_CONSTRAINTS t join INFORMATION_SCHEMA.KEY_COLUMN_USAGE k " +
+		"on k.CONSTRAINT_SCHEMA=t.CONSTRAINT_SCHEMA and k.CONSTRAINT_NAME=t.CONSTRAINT_NAME and k.TABLE_SCHEMA=t.TABLE_SCHEMA and k.TABLE_NAME=t.TABLE_NAME " +
+		"where t.CONSTRAINT_TYPE='PRIMARY KEY' and t.TABLE_SCHEMA=c.TABLE_SCHEMA and t.TABLE_NAME=c.TABLE_NAME and k.COLUMN_NAME=c.COLUMN_NAME), 0) " +
+		"from INFORMATION_SCHEMA.COLUMNS c where c.TABLE_SCHEMA=" + schemaArg + " and c.TABLE_NAME=" + tableArg + " order by c.ORDINAL_POSITION asc"
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"md5tabsum/checksum"
+)
+
+// The ROWHASH column must be lowercase hex digits on every dialect: the part sums and bucket numbers are compiled from
+// its hex digits and the diff command compares the fetched row hashes with the row hashes compiled in Go.
+func TestRowHashExpr(t *testing.T) {
+	defer func(h checksum.Hash) { hashAlgorithm = h }(hashAlgorithm)
+
+	tests := map[checksum.Hash]map[string]string{
+		checksum.MD5: {
+			"db2":        "lower(hex(hash_md5(R)))",
+			"duckdb":     "md5(R)",
+			"exasol":     "hash_md5(R)",
+			"mssql":      "lower(convert(varchar(32), HashBytes('MD5', R), 2))",
+			"mysql":      "md5(R)",
+			"oracle":     "lower(rawtohex(standard_hash(R, 'MD5')))",
+			"postgresql": "md5(R)",
+			"sqlite":     "md5(R)",
+		},
+		checksum.SHA1: {
+			"db2":        "lower(hex(hash_sha1(R)))",
+			"duckdb":     "sha1(R)",
+			"exasol":     "hash_sha1(R)",
+			"mssql":      "lower(convert(varchar(40), HashBytes('SHA1', R), 2))",
+			"mysql":      "sha1(R)",
+			"oracle":     "lower(rawtohex(standard_hash(R, 'SHA1')))",
+			"postgresql": "encode(digest(R, 'sha1'), 'hex')",
+			"sqlite":     "sha1(R)",
+		},
+		checksum.SHA256: {
+			"db2":        "lower(hex(hash_sha256(R)))",
+			"duckdb":     "sha256(R)",
+			"exasol":     "hash_sha256(R)",
+			"mssql":      "lower(convert(varchar(64), HashBytes('SHA2_256', R), 2))",
+			"mysql":      "sha2(R, 256)",
+			"oracle":     "lower(rawtohex(standard_hash(R, 'SHA256')))",
+			"postgresql": "encode(sha256(convert_to(R, 'UTF8')), 'hex')",
+			"sqlite":     "sha256(R)",
+		},
+	}
+	dialects := map[string]dialect{
+		"db2":        &db2DB{},
+		"duckdb":     &duckdbDB{},
+		"exasol":     &exasolDB{},
+		"mssql":      &mssqlDB{},
+		"mysql":      &mysqlDB{},
+		"oracle":     &oracleDB{},
+		"postgresql": &postgresqlDB{},
+		"sqlite":     &sqliteDB{},
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for h, want := range tests {
+		hashAlgorithm = h
+		for name, dia := range dialects {
+			if got := dia.rowHashExpr("R"); got != want[name] {
+				t.Errorf("%s %s: got row hash expression %s, want %s", name, h, got, want[name])
+			}
+		}
+
+		// the row hash of SQLite is compiled by the registered hash functions
+		var rowHash string
+		if err = db.QueryRow("select " + dialects["sqlite"].rowHashExpr("'asdf'")).Scan(&rowHash); err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(fmt.Sprintf("^[0-9a-f]{%d}$", h.Size())).MatchString(rowHash) || rowHash != h.Sum("asdf") {
+			t.Errorf("sqlite %s: got row hash %s, want %s", h, rowHash, h.Sum("asdf"))
+		}
+	}
+}
+
+// Trailing blanks of character values are not part of the canonical string, like in the reference implementation.
+func TestCharTrailingBlanks(t *testing.T) {
+	defer func(h checksum.Hash) { hashAlgorithm = h }(hashAlgorithm)
+	hashAlgorithm = checksum.MD5
+
+	tests := map[string]dialect{
+		"db2":        &db2DB{},
+		"duckdb":     &duckdbDB{},
+		"exasol":     &exasolDB{},
+		"mssql":      &mssqlDB{},
+		"mysql":      &mysqlDB{},
+		"oracle":     &oracleDB{},
+		"postgresql": &postgresqlDB{},
+		"sqlite":     &sqliteDB{},
+	}
+	for name, dia := range tests {
+		if expr := dia.canonicalExpr("C", charType); !strings.Contains(expr, "rtrim(C)") && !strings.Contains(expr, "trim(trailing ' ' from C)") {
+			t.Errorf("%s: character column isn't right trimmed: %s", name, expr)
+		}
+	}
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+// diffCommand locates the differing rows of two tables.
+const diffCommand string = "diff"
+
+const (
+	diffLeafRows    int = 1000    // max number of rows (of both sides) of a key range, whose row hashes are compared directly
+	diffSplitFactor int = 16      // number of sub ranges of a mismatching key range
+	diffMaxModulus  int = 1 << 28 // max number of key hash buckets (16^7), the bucket number is taken from 8 hex digits of the key hash
+	diffMaxBuckets  int = 1000    // max number of key hash buckets of a filter, e.g. Oracle limits an IN list to 1000 values
+)
+
+// diff results of a row
+const (
+	diffMissing string = "MISSING" // the row exists in the source table only
+	diffExtra   string = "EXTRA"   // the row exists in the target table only
+	diffChanged string = "CHANGED" // the row exists in both tables, but has different column values
+)
+
+// engineProvider is implemented by all DBMS instances, whose checksums are compiled by the engine.
+type engineProvider interface {
+	checksumEngine() *engine
+}
+
+// diffSide is the table of one side of a diff, which is either the source or the target table.
+type diffSide struct {
+	e     *engine
+	db    *sql.DB
+	conn  *sql.Conn // database session, which executes all statements of the side
+	table string
+	cols  []column
+	keys  []column
+}
+
+// keyRange is a range of keys [low, high), a nil bound is unbounded.
+// The NULL range contains all rows with at least one NULL key column, it can't be split.
+// A key hash range contains the rows whose key hash bucket (the key hash modulo the modulus) is one of the bucket numbers,
+// the single bucket of the modulus 1 contains all rows.
+type keyRange struct {
+	low     []any
+	high    []any
+	null    bool
+	modulus int
+	numbers []int
+}
+
+// keyedRow is the key and the row hash of a table row.
+type keyedRow struct {
+	key     string // canonical key, used to match the rows of both sides
+	display string // key columns and their values, e.g. ID=3
+	rowHash string
+}
+
+// openSide opens the table of a diff side, which is specified as <DBMS name>.<instance ID>.<table>.
+func openSide(ctx context.Context, name string, keys []string) (*diffSide, error) {
+	instance, table, ok := splitTableKey(name)
+	if !ok || !instanceActive[instance] {
+		return nil, errors.New(formatMsg(mm033, name))
+	}
+	provider, ok := instanceName(instance).(engineProvider)
+	if !ok {
+		return nil, errors.New(formatMsg(mm034, instance))
+	}
+
+	side := diffSide{e: provider.checksumEngine()}
+	db, _, err := openInstance(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	side.db = db
+	if side.conn, err = side.e.openSession(ctx, db); err != nil {
+		return &side, err
+	}
+	if queryName, _, isQuery := side.e.cfg.findQuery(table); isQuery {
+		side.table = queryName
+	} else {
+		tables, err := side.e.matchTables(ctx, side.conn, table)
+		if err != nil {
+			return &side, err
+		}
+		side.table = tables[0]
+	}
+	if side.cols, err = side.e.columns(ctx, side.conn, side.table); err != nil {
+		return &side, err
+	}
+	for _, key := range keys {
+		found := false
+		for _, col := range side.cols {
+			if strings.EqualFold(col.name, key) {
+				side.keys = append(side.keys, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &side, side.e.logError(errors.New(formatMsg(mm069, key, side.table)))
+		}
+	}
+	// the key columns are used to match the rows even if they are not selected to be checksummed
+	side.cols, err = side.e.selectColumns(side.table, side.cols)
+	return &side, err
+}
+
+// close closes the database session and connection of a diff side.
+func (s *diffSide) close() {
+	if s == nil || s.db == nil {
+		return
+	}
+	if s.conn != nil {
+		s.e.closeSession(s.conn)
+	}
+	s.db.Close()
+}
+
+// name returns the name of the table of a diff side, e.g. mysql.test1.TAB2.
+func (s *diffSide) name() string {
+	return s.e.cfg.instance + "." + s.table
+}
+
+// literal converts a key value into a SQL literal.
+func (s *diffSide) literal(col column, value any) (string, error) {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	switch class := s.e.dia.typeClass(col.dataType); {
+	case class == decimalType || class == floatType:
+		return checksum.Canonical(decimalType, value)
+	case class == dateType || class == timestampType:
+		// the key value may be read from the other side, thus it is converted by its canonical string
+		canonical, err := checksum.Canonical(timestampType, value)
+		if err != nil {
+			return "", err
+		}
+		t, err := time.Parse(checksum.TimestampLayout, canonical)
+		if err != nil {
+			return "", err
+		}
+		return s.e.dia.timestampLiteral(t, class), nil
+	default:
+		return s.e.dia.quoteLiteral(fmt.Sprint(value)), nil
+	}
+}
+
+// compareKeys returns the predicate which compares the key columns with the key values by an operator (> or <),
+// the key values are included if inclusive is true, e.g. (K1 > 1) or (K1 = 1 and K2 >= 'A')
+func (s *diffSide) compareKeys(values []any, operator string, inclusive bool) (string, error) {
+	var terms []string
+	var equal []string
+	for i, key := range s.keys {
+		ident := s.e.dia.quoteIdent(key.name)
+		literal, err := s.literal(key, values[i])
+		if err != nil {
+			return "", err
+		}
+		op := operator
+		if inclusive && i == len(s.keys)-1 {
+			op += "="
+		}
+		terms = append(terms, "("+strings.Join(append(equal, ident+" "+op+" "+literal), " and ")+")")
+		equal = append(equal, ident+" = "+literal)
+	}
+	return "(" + strings.Join(terms, " or ") + ")", nil
+}
+
+// filter returns the predicate of a key range.
+func (s *diffSide) filter(r keyRange) (string, error) {
+	var predicates []string
+	switch {
+	case r.modulus == 1:
+		return "", nil
+	case r.modulus > 1:
+		numbers := make([]string, 0, len(r.numbers))
+		for _, n := range r.numbers {
+			numbers = append(numbers, strconv.Itoa(n))
+		}
+		return s.e.dia.bucketExpr(s.e.rowHashExpr(s.keys), r.modulus) + " in (" + strings.Join(numbers, ", ") + ")", nil
+	}
+	if r.null {
+		for _, key := range s.keys {
+			predicates = append(predicates, s.e.dia.quoteIdent(key.name)+" is NULL")
+		}
+		return "(" + strings.Join(predicates, " or ") + ")", nil
+	}
+	for _, key := range s.keys {
+		predicates = append(predicates, s.e.dia.quoteIdent(key.name)+" is not NULL")
+	}
+	if r.low != nil {
+		predicate, err := s.compareKeys(r.low, ">", true)
+		if err != nil {
+			return "", err
+		}
+		predicates = append(predicates, predicate)
+	}
+	if r.high != nil {
+		predicate, err := s.compareKeys(r.high, "<", false)
+		if err != nil {
+			return "", err
+		}
+		predicates = append(predicates, predicate)
+	}
+	return strings.Join(predicates, " and "), nil
+}
+
+// keyList returns the comma separated list of the quoted key columns.
+func (s *diffSide) keyList() string {
+	keys := make([]string, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, s.e.dia.quoteIdent(key.name))
+	}
+	return strings.Join(keys, ", ")
+}
+
+// bucketChecksum compiles the number of rows and the checksum of a key range.
+func (s *diffSide) bucketChecksum(ctx context.Context, r keyRange) (int, string, error) {
+	filter, err := s.filter(r)
+	if err != nil {
+		return 0, "", s.e.logError(err)
+	}
+	return s.e.checksum(ctx, s.conn, s.table, s.cols, filter)
+}
+
+// hashBuckets compiles the aggregates of the key hash buckets of a key hash range by the given modulus.
+func (s *diffSide) hashBuckets(ctx context.Context, r keyRange, modulus int) (map[int]*checksum.Aggregate, error) {
+	filter, err := s.filter(r)
+	if err != nil {
+		return nil, s.e.logError(err)
+	}
+	return s.e.bucketAggregates(ctx, s.conn, s.table, s.cols, filter, s.keys, modulus)
+}
+
+// splitKeys returns the keys of the rows at the given row numbers (ordered by key) of a key range.
+func (s *diffSide) splitKeys(ctx context.Context, r keyRange, rowNumbers []int) ([][]any, error) {
+	var splitKeys [][]any
+
+	filter, err := s.filter(r)
+	if err != nil {
+		return splitKeys, s.e.logError(err)
+	}
+	numbers := make([]string, 0, len(rowNumbers))
+	for _, n := range rowNumbers {
+		numbers = append(numbers, strconv.Itoa(n))
+	}
+	stmt := "select " + s.keyList() + " from (select " + s.keyList() + ", row_number() over (order by " + s.keyList() + ") RN from " + s.e.source(s.table, filter) + ") t where RN in (" + strings.Join(numbers, ", ") + ") order by RN"
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, s.e.logPrefix(), "SQL[4]: "+stmt)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := s.conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return splitKeys, s.e.logError(err)
+	}
+	defer rowSet.Close()
+
+	for rowSet.Next() {
+		values := make([]any, len(s.keys))
+		valuePtrs := make([]any, len(s.keys))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err = rowSet.Scan(valuePtrs...); err != nil {
+			return splitKeys, s.e.logError(err)
+		}
+		splitKeys = append(splitKeys, values)
+	}
+	if err = rowSet.Err(); err != nil {
+		return splitKeys, s.e.logError(err)
+	}
+	return splitKeys, nil
+}
+
+// canonicalKey returns the canonical key of key values, which is used to match the rows of both sides.
+func (s *diffSide) canonicalKey(values []any) (string, string, error) {
+	canonicalValues := make([]string, len(values))
+	displayValues := make([]string, len(values))
+	for i, value := range values {
+		class := s.e.dia.typeClass(s.keys[i].dataType)
+		if class == charType {
+			// the canonical string of a character value is its hash, which is not suitable to be displayed
+			class = otherType
+		}
+		v, err := checksum.Canonical(class, value)
+		if err != nil {
+			return "", "", err
+		}
+		if s.e.dia.typeClass(s.keys[i].dataType) == charType {
+			v = strings.TrimRight(v, " ")
+		}
+		canonicalValues[i] = v
+		displayValues[i] = s.keys[i].name + "=" + v
+	}
+	return strings.Join(canonicalValues, "\x00"), strings.Join(displayValues, ", "), nil
+}
+
+// rows returns the keys and row hashes of all rows of a key range.
+func (s *diffSide) rows(ctx context.Context, r keyRange) ([]keyedRow, error) {
+	var rows []keyedRow
+
+	filter, err := s.filter(r)
+	if err != nil {
+		return rows, s.e.logError(err)
+	}
+	// in client mode the row hashes are compiled in Go
+	var stmt string
+	if s.e.cfg.mode == clientMode {
+		names := make([]string, 0, len(s.cols))
+		for _, col := range s.cols {
+			names = append(names, s.e.dia.quoteIdent(col.name))
+		}
+		stmt = "select " + s.keyList() + ", " + strings.Join(names, ", ") + " from " + s.e.source(s.table, filter)
+	} else {
+		stmt = "select " + s.keyList() + ", " + s.e.rowHashExpr(s.cols) + " ROWHASH from " + s.e.source(s.table, filter)
+	}
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, s.e.logPrefix(), "SQL[5]: "+stmt)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := s.conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return rows, s.e.logError(err)
+	}
+	defer rowSet.Close()
+
+	numColumns := len(s.keys) + 1
+	if s.e.cfg.mode == clientMode {
+		numColumns = len(s.keys) + len(s.cols)
+	}
+	values := make([]any, numColumns)
+	valuePtrs := make([]any, numColumns)
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rowSet.Next() {
+		var row keyedRow
+		if err = rowSet.Scan(valuePtrs...); err != nil {
+			return rows, s.e.logError(err)
+		}
+		if row.key, row.display, err = s.canonicalKey(values[:len(s.keys)]); err != nil {
+			return rows, s.e.logError(err)
+		}
+		if s.e.cfg.mode == clientMode {
+			classes := make([]checksum.Class, 0, len(s.cols))
+			for _, col := range s.cols {
+				classes = append(classes, s.e.dia.typeClass(col.dataType))
+			}
+			if row.rowHash, err = hashAlgorithm.Row(classes, values[len(s.keys):]); err != nil {
+				return rows, s.e.logError(err)
+			}
+		} else {
+			row.rowHash = fmt.Sprint(values[len(s.keys)])
+			if b, ok := values[len(s.keys)].([]byte); ok {
+				row.rowHash = string(b)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err = rowSet.Err(); err != nil {
+		return rows, s.e.logError(err)
+	}
+	return rows, nil
+}
+
+// collection of diff state
+type differ struct {
+	source   *diffSide
+	target   *diffSide
+	hashed   bool // the rows are compared by key hash buckets instead of key ranges, see compareHashBuckets
+	buckets  int
+	missing  int
+	extra    int
+	changed  int
+	messages []string
+}
+
+// both runs a function for the source and the target side concurrently.
+func (d *differ) both(f func(s *diffSide) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, side := range []*diffSide{d.source, d.target} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = f(side)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// compareRange compares the checksums of a key range of both sides. Mismatching key ranges are split into
+// sub ranges by the keys of the side with more rows, until the rows of a key range can be compared directly.
+func (d *differ) compareRange(ctx context.Context, r keyRange) error {
+	var numRows [2]int
+	var checkSums [2]string
+	err := d.both(func(s *diffSide) error {
+		i := 0
+		if s == d.target {
+			i = 1
+		}
+		var err error
+		numRows[i], checkSums[i], err = s.bucketChecksum(ctx, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	d.buckets++
+	if numRows[0] == numRows[1] && checkSums[0] == checkSums[1] {
+		return nil
+	}
+	if r.null || numRows[0]+numRows[1] <= diffLeafRows {
+		return d.compareRows(ctx, r)
+	}
+
+	// split the key range by the keys of the side with more rows
+	side, n := d.source, numRows[0]
+	if numRows[1] > numRows[0] {
+		side, n = d.target, numRows[1]
+	}
+	step := (n + diffSplitFactor - 1) / diffSplitFactor
+	var rowNumbers []int
+	for rn := step + 1; rn <= n; rn += step {
+		rowNumbers = append(rowNumbers, rn)
+	}
+	splitKeys, err := side.splitKeys(ctx, r, rowNumbers)
+	if err != nil {
+		return err
+	}
+
+	low := r.low
+	lowKey := ""
+	if low != nil {
+		lowKey, _, _ = side.canonicalKey(low)
+	}
+	var subRanges []keyRange
+	for _, splitKey := range splitKeys {
+		key, _, err := side.canonicalKey(splitKey)
+		if err != nil {
+			return err
+		}
+		if key == lowKey {
+			// duplicate key
+			continue
+		}
+		subRanges = append(subRanges, keyRange{low: low, high: splitKey})
+		low, lowKey = splitKey, key
+	}
+	if len(subRanges) == 0 {
+		// the key range can't be split, e.g. because of duplicate keys
+		return d.compareRows(ctx, r)
+	}
+	subRanges = append(subRanges, keyRange{low: low, high: r.high})
+	for _, subRange := range subRanges {
+		if err = d.compareRange(ctx, subRange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rangeKeys returns true if the key columns of a diff side can be split into key ranges, which excludes character and
+// boolean columns. Character keys are not split into key ranges, because their order depends on the collation of each DBMS,
+// thus the key ranges of both sides wouldn't contain the same rows.
+func (s *diffSide) rangeKeys() bool {
+	for _, key := range s.keys {
+		switch s.e.dia.typeClass(key.dataType) {
+		case charType, booleanType:
+			return false
+		}
+	}
+	return true
+}
+
+// compareHashBuckets compares the key hash buckets of both sides, which replace the key ranges if a key column can't be
+// split into key ranges. The rows are distributed by the hash of their key columns, thus the buckets of both sides contain
+// the same keys regardless of the collation. Starting with diffSplitFactor buckets, the sub buckets of all mismatching
+// buckets are compiled by a modulus multiplied by diffSplitFactor, until the rows of a bucket can be compared directly.
+func (d *differ) compareHashBuckets(ctx context.Context) error {
+	ranges := []keyRange{{modulus: 1, numbers: []int{0}}}
+	for modulus := diffSplitFactor; len(ranges) > 0; modulus *= diffSplitFactor {
+		var split []int
+		var leaves []int
+		numRows := make(map[int]int)
+		for _, r := range ranges {
+			var aggs [2]map[int]*checksum.Aggregate
+			err := d.both(func(s *diffSide) error {
+				i := 0
+				if s == d.target {
+					i = 1
+				}
+				var err error
+				aggs[i], err = s.hashBuckets(ctx, r, modulus)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			numbers := slices.Collect(maps.Keys(aggs[0]))
+			for number := range aggs[1] {
+				if _, found := aggs[0][number]; !found {
+					numbers = append(numbers, number)
+				}
+			}
+			slices.Sort(numbers)
+			d.buckets += len(numbers)
+			for _, number := range numbers {
+				source, target := aggs[0][number], aggs[1][number]
+				if source != nil && target != nil && source.NumRows() == target.NumRows() && source.Checksum() == target.Checksum() {
+					continue
+				}
+				for _, agg := range []*checksum.Aggregate{source, target} {
+					if agg != nil {
+						numRows[number] += int(agg.NumRows())
+					}
+				}
+				if numRows[number] <= diffLeafRows || modulus >= diffMaxModulus {
+					leaves = append(leaves, number)
+				} else {
+					split = append(split, number)
+				}
+			}
+		}
+
+		// the rows of small buckets are compared together
+		var batch []int
+		var batchRows int
+		for i, number := range leaves {
+			batch = append(batch, number)
+			batchRows += numRows[number]
+			if i == len(leaves)-1 || len(batch) == diffMaxBuckets || batchRows+numRows[leaves[i+1]] > diffLeafRows*diffSplitFactor {
+				if err := d.compareRows(ctx, keyRange{modulus: modulus, numbers: batch}); err != nil {
+					return err
+				}
+				batch, batchRows = nil, 0
+			}
+		}
+
+		ranges = nil
+		for chunk := range slices.Chunk(split, diffMaxBuckets) {
+			ranges = append(ranges, keyRange{modulus: modulus, numbers: chunk})
+		}
+	}
+	return nil
+}
+
+// compareRows compares the row hashes of all rows of a key range of both sides.
+func (d *differ) compareRows(ctx context.Context, r keyRange) error {
+	var rows [2][]keyedRow
+	err := d.both(func(s *diffSide) error {
+		i := 0
+		if s == d.target {
+			i = 1
+		}
+		var err error
+		rows[i], err = s.rows(ctx, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// rows with duplicate keys are matched by their combined row hashes
+	hashes := [2]map[string][]string{make(map[string][]string), make(map[string][]string)}
+	display := make(map[string]string)
+	for i := range rows {
+		for _, row := range rows[i] {
+			hashes[i][row.key] = append(hashes[i][row.key], row.rowHash)
+			if _, found := display[row.key]; !found {
+				// the key columns of the source table are displayed, if both tables contain the row
+				display[row.key] = row.display
+			}
+		}
+	}
+	var messages []string
+	for key, sourceHashes := range hashes[0] {
+		targetHashes, found := hashes[1][key]
+		switch {
+		case !found:
+			d.missing++
+			messages = append(messages, display[key]+":"+diffMissing)
+		default:
+			sort.Strings(sourceHashes)
+			sort.Strings(targetHashes)
+			if strings.Join(sourceHashes, ",") != strings.Join(targetHashes, ",") {
+				d.changed++
+				messages = append(messages, display[key]+":"+diffChanged)
+			}
+		}
+	}
+	for key := range hashes[1] {
+		if _, found := hashes[0][key]; !found {
+			d.extra++
+			messages = append(messages, display[key]+":"+diffExtra)
+		}
+	}
+	sort.Strings(messages)
+	d.messages = append(d.messages, messages...)
+	return nil
+}
+
+// diffTables locates the missing, extra and changed rows of the target table compared to the source table.
+// The tables are specified by the command arguments as <DBMS name>.<instance ID>.<table>, the key columns by the -key option.
+func diffTables(ctx context.Context, args []string) int {
+	if len(args) != 2 || pr.key == "" {
+		simplelog.Write(simplelog.MULTI, mm032)
+		return md5Error
+	}
+	keys := strings.Split(strings.ReplaceAll(pr.key, " ", ""), ",")
+
+	var d differ
+	var err error
+	d.source, err = openSide(ctx, args[0], keys)
+	defer d.source.close()
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, err.Error())
+		return errorCode(ctx)
+	}
+	// the key columns of the target table can be mapped by a compare pair
+	d.target, err = openSide(ctx, args[1], targetColumns(args[0], args[1], keys))
+	defer d.target.close()
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, err.Error())
+		return errorCode(ctx)
+	}
+	simplelog.Write(simplelog.FILE, "Diff:", d.source.name(), "-", d.target.name(), "Key:", strings.Join(keys, ", "))
+	if d.hashed = !d.source.rangeKeys() || !d.target.rangeKeys(); d.hashed {
+		simplelog.Write(simplelog.FILE, "Diff: the key columns contain character or boolean columns, the rows are compared by key hash buckets")
+		if err = d.compareHashBuckets(ctx); err != nil {
+			return errorCode(ctx)
+		}
+	} else {
+		// rows with NULL keys are compared separately, because they are not part of any key range
+		for _, r := range []keyRange{{}, {null: true}} {
+			if err = d.compareRange(ctx, r); err != nil {
+				return errorCode(ctx)
+			}
+		}
+	}
+
+	for _, msg := range d.messages {
+		simplelog.Write(simplelog.MULTI, msg)
+	}
+	compared := "key ranges"
+	if d.hashed {
+		compared = "key hash buckets"
+	}
+	simplelog.Write(simplelog.MULTI, fmt.Sprintf("%s - %s: %d missing, %d extra, %d changed rows (%d %s compared)", d.source.name(), d.target.name(), d.missing, d.extra, d.changed, d.buckets, compared))
+	if d.missing+d.extra+d.changed > 0 {
+		return md5Mismatch
+	}
+	return md5Ok
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRangeKeys(t *testing.T) {
+	e := newEngine(&config{instance: "sqlite.test"}, &sqliteDB{})
+	tests := []struct {
+		keys []column
+		want bool
+	}{
+		{[]column{{"ID", "INTEGER", 1}}, true},
+		{[]column{{"ID", "INTEGER", 1}, {"TS", "TIMESTAMP", 2}}, true},
+		{[]column{{"CODE", "TEXT", 1}}, false},
+		{[]column{{"ID", "INTEGER", 1}, {"CODE", "VARCHAR(20)", 2}}, false},
+	}
+	for _, test := range tests {
+		s := diffSide{e: e, keys: test.keys}
+		if got := s.rangeKeys(); got != test.want {
+			t.Errorf("keys %v: got %t, want %t", test.keys, got, test.want)
+		}
+	}
+}
+
+func TestHashFilter(t *testing.T) {
+	s := diffSide{e: newEngine(&config{instance: "sqlite.test"}, &sqliteDB{}), keys: []column{{"CODE", "TEXT", 1}}}
+	tests := []struct {
+		r    keyRange
+		want string
+	}{
+		{keyRange{modulus: 1, numbers: []int{0}}, ""},
+		{keyRange{modulus: 256, numbers: []int{3, 19}}, `hex_to_int(substr(md5(coalesce(md5(rtrim("CODE")), 'null')), 1, 8)) % 256 in (3, 19)`},
+	}
+	for _, test := range tests {
+		if got, err := s.filter(test.r); err != nil || got != test.want {
+			t.Errorf("modulus %d: got %q (%v), want %q", test.r.modulus, got, err, test.want)
+		}
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 10, 30, 0, 500000000, time.UTC)
+	tests := []struct {
+		dia   dialect
+		col   column
+		value any
+		want  string
+	}{
+		{&oracleDB{}, column{"TS", "TIMESTAMP(6)", 1}, ts, "to_timestamp('2024-01-02 10:30:00.500000', 'YYYY-MM-DD HH24:MI:SS.FF6')"},
+		{&oracleDB{}, column{"D", "DATE", 1}, ts, "to_date('2024-01-02 10:30:00', 'YYYY-MM-DD HH24:MI:SS')"},
+		{&db2DB{}, column{"D", "DATE", 1}, ts, "date('2024-01-02')"},
+		{&postgresqlDB{}, column{"TS", "timestamp", 1}, "2024-01-02 10:30:00.5", "timestamp '2024-01-02 10:30:00.500000'"},
+		{&sqliteDB{}, column{"TS", "TIMESTAMP", 1}, []byte("2024-01-02 10:30:00"), "'2024-01-02 10:30:00'"},
+		{&sqliteDB{}, column{"ID", "DECIMAL(10,2)", 1}, "1.50", "1.5"},
+	}
+	for _, test := range tests {
+		s := diffSide{e: newEngine(&config{}, test.dia)}
+		if got, err := s.literal(test.col, test.value); err != nil || got != test.want {
+			t.Errorf("%T %s: got %q (%v), want %q", test.dia, test.col.dataType, got, err, test.want)
+		}
+	}
+}
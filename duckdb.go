@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+// inMemoryFile is the DuckDB file name of an in-memory database.
+const inMemoryFile string = ":memory:"
+
+type duckdbDB struct {
+	cfg  config
+	path string // DuckDB specific
+}
+
+func (d *duckdbDB) instance() string {
+	return d.cfg.instance
+}
+
+func (d *duckdbDB) schema() string {
+	if d.cfg.schema == "" {
+		return "main"
+	}
+	return d.cfg.schema
+}
+
+func (d *duckdbDB) table() []string {
+	return d.cfg.table
+}
+
+func (d *duckdbDB) file() string {
+	if d.path == "" {
+		return inMemoryFile
+	}
+	return d.path
+}
+
+func (d *duckdbDB) logPrefix() string {
+	return "[" + d.instance() + "] -"
+}
+
+// ----------------------------------------------------------------------------
+func (d *duckdbDB) openDB(ctx context.Context, password string) (*sql.DB, error) {
+	tableFilter := strings.Join(d.table(), ", ")
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, d.logPrefix(), "Profile parameter:", "File:"+d.file()+",", "Schema:"+d.schema()+",", "Table:"+tableFilter)
+	// a database file is opened read-only, an in-memory database can't be opened read-only
+	dsn := d.file()
+	if dsn != inMemoryFile {
+		dsn += "?access_mode=read_only"
+	}
+	// Hint: The driver is only available if md5tabsum was built with the 'duckdb' build tag (see duckdb_driver.go).
+	db, err := connectDB(ctx, "duckdb", dsn)
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, d.logPrefix(), err.Error())
+		return db, err
+	}
+	return db, err
+}
+
+func (d *duckdbDB) closeDB(db *sql.DB) error {
+	return db.Close()
+}
+
+func (d *duckdbDB) queryDB(ctx context.Context, db *sql.DB) error {
+	return d.checksumEngine().run(ctx, db)
+}
+
+func (d *duckdbDB) checksumEngine() *engine {
+	cfg := d.cfg
+	cfg.schema = d.schema()
+	return newEngine(&cfg, d)
+}
+
+// ----------------------------------------------------------------------------
+// dialect
+
+func (d *duckdbDB) sessionStmt() []string {
+	return nil
+}
+
+func (d *duckdbDB) versionStmt() string {
+	return "select version(), null"
+}
+
+func (d *duckdbDB) transientErrors() []string {
+	return nil
+}
+
+// Hint: Views are found as well, thus Parquet or CSV files can be checked by views like: create view T1 as select * from 't1.parquet'
+func (d *duckdbDB) tableStmt(schema, table string) (string, []any) {
+	return "select TABLE_NAME from INFORMATION_SCHEMA.TABLES where TABLE_SCHEMA=? and TABLE_NAME like ?", []any{schema, table}
+}
+
+func (d *duckdbDB) columnStmt(schema, table string) (string, []any) {
+	return "select COLUMN_NAME, DATA_TYPE, ORDINAL_POSITION from INFORMATION_SCHEMA.COLUMNS where TABLE_SCHEMA=? and TABLE_NAME=? order by ORDINAL_POSITION asc", []any{schema, table}
+}
+
+func (d *duckdbDB) structureStmt(schema, table string) (string, []any) {
+	return infoSchemaStructureStmt("c.DATA_TYPE", "?", "?"), []any{schema, table}
+}
+
+func (d *duckdbDB) quoteIdent(ident string) string {
+	return "\"" + strings.ReplaceAll(ident, "\"", "\"\"") + "\""
+}
+
+func (d *duckdbDB) quoteLiteral(literal string) string {
+	return "'" + strings.ReplaceAll(literal, "'", "''") + "'"
+}
+
+func (d *duckdbDB) timestampLiteral(value time.Time, class typeClass) string {
+	if class == dateType {
+		return "date '" + value.Format("2006-01-02") + "'"
+	}
+	return "timestamp '" + value.Format(checksum.TimestampLayout) + "'"
+}
+
+func (d *duckdbDB) typeClass(columnType string) typeClass {
+	columnType = strings.ToUpper(columnType)
+	switch {
+	case strings.Contains(columnType, "CHAR"), strings.Contains(columnType, "TEXT"), strings.Contains(columnType, "STRING"):
+		return charType
+	case strings.Contains(columnType, "DECIMAL"), strings.Contains(columnType, "NUMERIC"):
+		return decimalType
+	case strings.Contains(columnType, "DOUBLE"), strings.Contains(columnType, "FLOAT"), strings.Contains(columnType, "REAL"):
+		return floatType
+	case strings.Contains(columnType, "TIME"), strings.Contains(columnType, "DATE"):
+		return timestampType
+	case strings.Contains(columnType, "BOOLEAN"):
+		return booleanType
+	default:
+		return otherType
+	}
+}
+
+func (d *duckdbDB) canonicalExpr(column string, class typeClass) string {
+	switch class {
+	case charType:
+		// calculate the hash of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
+		return "coalesce(" + d.hashExpr("rtrim("+column+")") + ", 'null')"
+	case decimalType:
+		// Hint: A DECIMAL is converted into a string including all digits of its scale, e.g. 0.90, thus trailing zeros are removed.
+		return "coalesce(case when contains(" + column + "::varchar, '.') then rtrim(rtrim(" + column + "::varchar, '0'), '.') else " + column + "::varchar end, 'null')"
+	case floatType:
+		// Hint: A DOUBLE without fractional digits is converted into a string with a trailing '.0', e.g. 8192.0
+		return "coalesce(case when " + column + " = trunc(" + column + ") and abs(" + column + ") < 1e15 then " + column + "::bigint::varchar else " + column + "::varchar end, 'null')"
+	case timestampType:
+		return "coalesce(strftime(" + column + ", '%Y-%m-%d %H:%M:%S.%f'), 'null')"
+	case booleanType:
+		return "coalesce(" + column + "::integer::varchar, 'null')"
+	default:
+		return "coalesce(" + column + "::varchar, 'null')"
+	}
+}
+
+func (d *duckdbDB) concatExpr(columns []string) string {
+	return strings.Join(columns, " || ")
+}
+
+// hashExpr returns the hash (lowercase hex digits) of a string expression.
+func (d *duckdbDB) hashExpr(expr string) string {
+	switch hashAlgorithm {
+	case checksum.SHA1:
+		return "sha1(" + expr + ")"
+	case checksum.SHA256:
+		return "sha256(" + expr + ")"
+	default:
+		return "md5(" + expr + ")"
+	}
+}
+
+func (d *duckdbDB) rowHashExpr(row string) string {
+	return d.hashExpr(row)
+}
+
+// hexToIntExpr converts 8 hex digits of a hash expression, starting at the given position, into a BIGINT.
+func (d *duckdbDB) hexToIntExpr(hash string, start int) string {
+	return "('0x' || substring(" + hash + ", " + strconv.Itoa(start) + ", 8))::bigint"
+}
+
+func (d *duckdbDB) partSumExpr(start int) string {
+	return "sum(" + d.hexToIntExpr("ROWHASH", start) + ")::varchar"
+}
+
+func (d *duckdbDB) partXorExpr(start int) string {
+	return "bit_xor(" + d.hexToIntExpr("ROWHASH", start) + ")::varchar"
+}
+
+func (d *duckdbDB) bucketExpr(hash string, buckets int) string {
+	return d.hexToIntExpr(hash, 1) + " % " + strconv.Itoa(buckets)
+}
+
+func (d *duckdbDB) checksumExpr() string {
+	return "count(1) NUMROWS, coalesce(" + d.hashExpr(strings.Join(partSumExprs(d), " || ")) + ", '" + hashAlgorithm.Empty() + "') CHECKSUM"
+}
@@ -0,0 +1,20 @@
+//go:build duckdb
+
+package main
+
+// The DuckDB driver embeds the DuckDB library (cgo), thus it is only linked if md5tabsum is built with the 'duckdb' build tag.
+import (
+	"github.com/marcboeker/go-duckdb"
+
+	"md5tabsum/checksum"
+)
+
+// DECIMAL values are returned as duckdb.Decimal, which is converted into its string representation for the client mode.
+func init() {
+	checksum.RegisterConverter(func(value any) (any, bool) {
+		if d, ok := value.(duckdb.Decimal); ok {
+			return d.String(), true
+		}
+		return value, false
+	})
+}
@@ -0,0 +1,8 @@
+//go:build duckdb
+
+package main
+
+// The DuckDB test uses an in-memory database, which is shared by all connections of the connection pool.
+func init() {
+	t1Instances["duckdb.test"] = t1Instance{driver: "duckdb", database: func(cfg config) database { return &duckdbDB{cfg: cfg} }}
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+// hashAlgorithm is the hash algorithm of the row hashes and the table checksums, see the -hash option.
+var hashAlgorithm = checksum.MD5
+
+// aggregation is the version of the aggregation of the row hashes into the table checksums, see the Aggregation parameter.
+var aggregation = checksum.V1
+
+// collection of table column properties
+type column struct {
+	name     string
+	dataType string
+	position int
+}
+
+// sessionSlots limits the number of concurrent database sessions of all instances, see the common Parallelism parameter.
+// A nil channel doesn't limit the number of sessions.
+var sessionSlots chan struct{}
+
+// querier is a database session, either the connection pool (*sql.DB) or a dedicated connection (*sql.Conn).
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// engine compiles the checksums of all configured tables of a DBMS instance.
+// All DBMS specific SQL is provided by the dialect of the instance.
+type engine struct {
+	cfg *config
+	dia dialect
+}
+
+// newEngine creates a checksum engine for an instance config and its dialect.
+func newEngine(cfg *config, dia dialect) *engine {
+	return &engine{cfg: cfg, dia: dia}
+}
+
+func (e *engine) logPrefix() string {
+	return "[" + e.cfg.instance + "] -"
+}
+
+// logError writes an error to STDOUT and the log file and returns it to the caller.
+func (e *engine) logError(err error) error {
+	simplelog.Write(simplelog.MULTI, e.logPrefix(), err.Error())
+	return err
+}
+
+// run compiles the checksum of all tables matching the configured table parameter, in case of the -explain option
+// it explains the checksum statements of the tables instead. The tables are distributed over a pool of workers, the number of workers is the configured parallelism of the instance.
+// Transient errors are retried according to the retry policy of the instance, a table is retried as a whole.
+// If the run is interrupted, the running and all remaining tables are reported as interrupted. If a table fails, no further
+// tables are started and the remaining tables are reported as skipped.
+func (e *engine) run(ctx context.Context, db *sql.DB) error {
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, e.logPrefix(), "Mode:"+e.cfg.mode+",", "Parallelism:", e.cfg.parallelism)
+	db.SetMaxOpenConns(e.maxSessions())
+	db.SetMaxIdleConns(e.maxSessions())
+
+	// PREPARE: filter for all existing DB tables based on the configured table parameter (the tables parameter can include placeholders, e.g. %)
+	var tableNames []string
+	_, err := e.retry(ctx, "Table lookup", func() error {
+		err := e.prepareSession(ctx, db)
+		if err == nil {
+			tableNames, err = e.findTables(ctx, db)
+		}
+		return err
+	})
+	if err != nil {
+		return interrupted(ctx, err)
+	}
+
+	// EXECUTE: compile MD5 for all found tables, no further tables are started after a table has failed
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	tables := make(chan string)
+	errs := make([]error, min(e.cfg.parallelism, len(tableNames)))
+	skip := func(table string) {
+		if pr.explain {
+			return
+		}
+		result := newResult(e.cfg.instance, e.cfg.schema, table)
+		if ctx.Err() != nil {
+			result.err = interrupted(ctx, ctx.Err())
+			writeInterrupted(result)
+			return
+		}
+		result.err = errSkipped
+		writeSkipped(result)
+	}
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for table := range tables {
+				if failed.Load() {
+					skip(table)
+					continue
+				}
+				if pr.explain {
+					if errs[i] = interrupted(ctx, e.explainTable(ctx, db, table)); errs[i] != nil {
+						failed.Store(true)
+					}
+					continue
+				}
+				start := time.Now()
+				result := newResult(e.cfg.instance, e.cfg.schema, table)
+				var retries int
+				retries, errs[i] = e.retry(ctx, "Table "+table, func() error {
+					var err error
+					result.numRows, result.checksum, result.buckets, err = e.tableChecksum(ctx, db, table)
+					return err
+				})
+				result.retries += retries
+				result.duration = time.Since(start)
+				if errs[i] != nil {
+					failed.Store(true)
+					errs[i] = interrupted(ctx, errs[i])
+					result.err = errs[i]
+					if errors.Is(errs[i], errInterrupted) {
+						writeInterrupted(result)
+					} else {
+						addResult(result)
+					}
+					continue
+				}
+				writeChecksum(result)
+			}
+		}()
+	}
+	for _, table := range tableNames {
+		if ctx.Err() != nil {
+			failed.Store(true)
+		}
+		if failed.Load() {
+			skip(table)
+			continue
+		}
+		tables <- table
+	}
+	close(tables)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// maxSessions returns the maximum number of concurrent database sessions of the instance, which is the parallelism
+// multiplied by the highest number of key range buckets of a table. Row hash buckets are compiled in a single session.
+func (e *engine) maxSessions() int {
+	buckets := 1
+	for _, opt := range e.cfg.tableOpt {
+		if opt.bucketKey != "" {
+			buckets = max(buckets, opt.buckets)
+		}
+	}
+	return e.cfg.parallelism * buckets
+}
+
+// openSession opens a dedicated database session and executes the session statements of the dialect.
+// If the number of concurrent sessions of all instances is limited, it waits for a free session slot.
+func (e *engine) openSession(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
+	if sessionSlots != nil {
+		select {
+		case sessionSlots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		e.releaseSlot()
+		return nil, e.logError(err)
+	}
+	if err = e.prepareSession(ctx, conn); err != nil {
+		e.closeSession(conn)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// closeSession closes a database session opened by openSession.
+func (e *engine) closeSession(conn *sql.Conn) {
+	conn.Close()
+	e.releaseSlot()
+}
+
+// releaseSlot releases the session slot of a closed session.
+func (e *engine) releaseSlot() {
+	if sessionSlots != nil {
+		<-sessionSlots
+	}
+}
+
+// prepareSession executes the session statements of the dialect.
+func (e *engine) prepareSession(ctx context.Context, q querier) error {
+	for _, stmt := range e.dia.sessionStmt() {
+		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[0]: "+stmt)
+		stmtCtx, cancel := statementContext(ctx)
+		_, err := q.ExecContext(stmtCtx, stmt)
+		cancel()
+		if err != nil {
+			return e.logError(err)
+		}
+	}
+	return nil
+}
+
+// findTables returns the names of all tables matching the configured table parameter, followed by the names of all queries.
+// Queries have no definition, thus they are skipped by the -structure option.
+func (e *engine) findTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	var tableNames []string
+
+	for _, table := range e.cfg.table {
+		foundTables, err := e.matchTables(ctx, db, table)
+		if err != nil {
+			return tableNames, err
+		}
+		tableNames = append(tableNames, foundTables...)
+	}
+
+	if pr.structure {
+		return tableNames, nil
+	}
+	return append(tableNames, e.cfg.queryNames()...), nil
+}
+
+// matchTables returns the names of all tables matching a table filter including placeholders (e.g. %).
+func (e *engine) matchTables(ctx context.Context, q querier, table string) ([]string, error) {
+	stmt, args := e.dia.tableStmt(e.cfg.schema, table)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[1]: "+stmt, "-", args)
+	foundTables, err := e.queryStrings(ctx, q, stmt, args...)
+	if err != nil {
+		return foundTables, e.logError(err)
+	}
+	if len(foundTables) == 0 {
+		// table doesn't exist in the DB schema
+		return foundTables, e.logError(errors.New("Table " + table + " could not be found."))
+	}
+	return foundTables, nil
+}
+
+// queryStrings returns the first column of all rows of a query result.
+func (e *engine) queryStrings(ctx context.Context, q querier, stmt string, args ...any) ([]string, error) {
+	var values []string
+
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := q.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return values, err
+	}
+	defer rowSet.Close()
+
+	for rowSet.Next() {
+		var value string
+		if err := rowSet.Scan(&value); err != nil {
+			return values, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rowSet.Err()
+}
+
+// columns returns the columns of a table ordered by their ordinal position.
+func (e *engine) columns(ctx context.Context, q querier, table string) ([]column, error) {
+	var cols []column
+
+	if _, isQuery := e.cfg.queries[table]; isQuery {
+		return e.queryColumns(ctx, q, table)
+	}
+
+	stmt, args := e.dia.columnStmt(e.cfg.schema, table)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[2]: "+stmt, "-", args)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := q.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return cols, e.logError(err)
+	}
+	defer rowSet.Close()
+
+	// gather table properties
+	for rowSet.Next() {
+		var col column
+		if err := rowSet.Scan(&col.name, &col.dataType, &col.position); err != nil {
+			return cols, e.logError(err)
+		}
+		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "Column", col.position, "of "+table+":", col.name, "("+col.dataType+")")
+		cols = append(cols, col)
+	}
+	if err := rowSet.Err(); err != nil {
+		return cols, e.logError(err)
+	}
+
+	return cols, nil
+}
+
+// likeMatch returns true if a name matches a pattern including placeholder characters (% for any number of characters
+// and _ for a single character) like the SQL LIKE operator, but case-insensitive.
+func likeMatch(pattern, name string) bool {
+	expr := strings.NewReplacer("%", ".*", "_", ".").Replace(regexp.QuoteMeta(pattern))
+	matched, _ := regexp.MatchString("(?is)^"+expr+"$", name)
+	return matched
+}
+
+// selectColumns returns the columns of a table to be checksummed, which are the columns matching the configured
+// Columns parameter (all columns if not set), except the columns matching the Excludecolumns parameter.
+// The columns keep their ordinal position order, thus the checksum doesn't depend on the order of the patterns.
+// However, if a compare pair maps the columns of the table, the compare and diff commands checksum the mapped columns
+// in the mapping order, the Columns and Excludecolumns parameters are applied to the mapped columns.
+func (e *engine) selectColumns(table string, cols []column) ([]column, error) {
+	if order := mappedColumns(e.cfg.instance, table); len(order) > 0 {
+		var err error
+		if cols, err = e.orderColumns(table, cols, order); err != nil {
+			return cols, err
+		}
+	}
+	opt := e.cfg.options(table)
+	if len(opt.columns) == 0 && len(opt.exclude) == 0 {
+		return cols, nil
+	}
+
+	matchAny := func(patterns []string, name string) bool {
+		for _, pattern := range patterns {
+			if likeMatch(pattern, name) {
+				return true
+			}
+		}
+		return false
+	}
+	var selected []column
+	var names []string
+	for _, col := range cols {
+		if (len(opt.columns) == 0 || matchAny(opt.columns, col.name)) && !matchAny(opt.exclude, col.name) {
+			selected = append(selected, col)
+			names = append(names, col.name)
+		}
+	}
+	if len(selected) == 0 {
+		return selected, e.logError(errors.New("No column of table " + table + " is selected."))
+	}
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, e.logPrefix(), "Table:"+table+",", "Columns:", strings.Join(names, ", "))
+	return selected, nil
+}
+
+// orderColumns returns the columns of a table in the order of the given column names.
+func (e *engine) orderColumns(table string, cols []column, order []string) ([]column, error) {
+	ordered := make([]column, 0, len(order))
+	for _, name := range order {
+		found := false
+		for _, col := range cols {
+			if strings.EqualFold(col.name, name) {
+				ordered = append(ordered, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ordered, e.logError(errors.New("Mapped column " + name + " could not be found in table " + table + "."))
+		}
+	}
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, e.logPrefix(), "Table:"+table+",", "Mapped columns:", strings.Join(order, ", "))
+	return ordered, nil
+}
+
+// queryColumns returns the result columns of a query. The data types are provided by the database driver.
+// Hint: The query isn't executed, it is embedded into a statement which returns no rows.
+func (e *engine) queryColumns(ctx context.Context, q querier, name string) ([]column, error) {
+	var cols []column
+
+	stmt := "select * from " + e.source(name, "1 = 0")
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[2]: "+stmt)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := q.QueryContext(ctx, stmt)
+	if err != nil {
+		return cols, e.logError(err)
+	}
+	defer rowSet.Close()
+
+	columnTypes, err := rowSet.ColumnTypes()
+	if err != nil {
+		return cols, e.logError(err)
+	}
+	names := make(map[string]bool)
+	for i, columnType := range columnTypes {
+		col := column{name: columnType.Name(), dataType: columnType.DatabaseTypeName(), position: i + 1}
+		if col.name == "" || names[strings.ToUpper(col.name)] {
+			return cols, e.logError(errors.New("Column " + strconv.Itoa(col.position) + " of query " + name + " requires a unique name."))
+		}
+		names[strings.ToUpper(col.name)] = true
+		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "Column", col.position, "of "+name+":", col.name, "("+col.dataType+")")
+		cols = append(cols, col)
+	}
+
+	return cols, rowSet.Err()
+}
+
+// checksumStmt builds the statement which compiles the checksum of a table.
+// Every column is converted into its canonical string, all columns of a row are concatenated and hashed,
+// finally the sums of the four 8 hex digit parts of all row hashes are hashed again, e.g. for PostgreSQL:
+//
+//	select count(1) NUMROWS,
+//	       coalesce(md5(sum(('x' || substring(ROWHASH, 1, 8))::bit(32)::bigint)::text ||
+//	                    sum(('x' || substring(ROWHASH, 9, 8))::bit(32)::bigint)::text ||
+//	                    sum(('x' || substring(ROWHASH, 17, 8))::bit(32)::bigint)::text ||
+//	                    sum(('x' || substring(ROWHASH, 25, 8))::bit(32)::bigint)::text),
+//	                'd41d8cd98f00b204e9800998ecf8427e') CHECKSUM
+//	from (select md5(<canonical columns>) ROWHASH from <schema>.<table>) t
+//
+// The optional filter restricts the rows of the table, e.g. to a key range.
+func (e *engine) checksumStmt(table string, cols []column, filter string) string {
+	return "select " + e.dia.checksumExpr() + " from (select " + e.rowHashExpr(cols) + " ROWHASH from " + e.source(table, filter) + ") t"
+}
+
+// rowHashExpr returns the expression which compiles the row hash of the given columns.
+func (e *engine) rowHashExpr(cols []column) string {
+	exprs := make([]string, 0, len(cols))
+	for _, col := range cols {
+		exprs = append(exprs, e.dia.canonicalExpr(e.dia.quoteIdent(col.name), e.dia.typeClass(col.dataType)))
+	}
+	return e.dia.rowHashExpr(e.dia.concatExpr(exprs))
+}
+
+// source returns the qualified table name including the configured table filter and an optional filter.
+// A query is embedded as subquery.
+func (e *engine) source(table, filter string) string {
+	source := e.cfg.schema + "." + e.dia.quoteIdent(table)
+	if query, isQuery := e.cfg.queries[table]; isQuery {
+		source = "(" + query + ") q"
+	}
+	tableFilter := e.cfg.options(table).filter
+	switch {
+	case tableFilter != "" && filter != "":
+		source += " where (" + tableFilter + ") and (" + filter + ")"
+	case tableFilter != "":
+		source += " where (" + tableFilter + ")"
+	case filter != "":
+		source += " where " + filter
+	}
+	return source
+}
+
+// tableChecksum compiles the number of rows and the checksum of a table in a dedicated database session.
+// If the table is split into buckets, the buckets are calculated in sessions of their own and their checksums are returned as well.
+// In case of the -structure option the number of columns and the structure checksum of the table are returned instead.
+func (e *engine) tableChecksum(ctx context.Context, db *sql.DB, table string) (int, string, []bucketResult, error) {
+	var numTableRows int
+	var checkSum string
+
+	if pr.structure {
+		numTableRows, checkSum, err := e.structureChecksum(ctx, db, table)
+		return numTableRows, checkSum, nil, err
+	}
+
+	conn, err := e.openSession(ctx, db)
+	if err != nil {
+		return numTableRows, checkSum, nil, err
+	}
+	cols, buckets, err := e.prepareTable(ctx, conn, table)
+	if err == nil && len(buckets) == 0 {
+		numTableRows, checkSum, err = e.checksum(ctx, conn, table, cols, "")
+	}
+	e.closeSession(conn)
+	if err != nil || len(buckets) == 0 {
+		return numTableRows, checkSum, nil, err
+	}
+
+	return e.bucketChecksum(ctx, db, table, cols, buckets)
+}
+
+// prepareTable returns the selected columns of a table and its buckets, if the table is split into buckets.
+func (e *engine) prepareTable(ctx context.Context, q querier, table string) ([]column, []bucket, error) {
+	allCols, err := e.columns(ctx, q, table)
+	if err != nil {
+		return allCols, nil, err
+	}
+	if len(allCols) == 0 {
+		return allCols, nil, e.logError(errors.New("Table " + table + " has no columns."))
+	}
+	cols, err := e.selectColumns(table, allCols)
+	if err != nil {
+		return cols, nil, err
+	}
+
+	opt := e.cfg.options(table)
+	if opt.filter != "" {
+		simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, e.logPrefix(), "Table:"+table+",", "Filter:", opt.filter)
+	}
+	if opt.buckets > 1 {
+		buckets, err := e.buckets(ctx, q, table, allCols, opt)
+		return cols, buckets, err
+	}
+	return cols, nil, nil
+}
+
+// checksum compiles the number of rows and the checksum of the rows of a table matching the filter (all rows if empty).
+// The checksum of aggregation version V1 is compiled by the DBMS completely, otherwise the DBMS compiles the aggregate only.
+func (e *engine) checksum(ctx context.Context, q querier, table string, cols []column, filter string) (int, string, error) {
+	var numTableRows int
+	var checkSum string
+
+	if e.cfg.mode == clientMode || aggregation != checksum.V1 {
+		agg, err := e.aggregate(ctx, q, table, cols, bucket{filter: filter})
+		return int(agg.NumRows()), agg.Checksum(), err
+	}
+
+	stmt := e.checksumStmt(table, cols, filter)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[3]: "+stmt)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	if err := q.QueryRowContext(ctx, stmt).Scan(&numTableRows, &checkSum); err != nil {
+		return numTableRows, checkSum, e.logError(err)
+	}
+
+	return numTableRows, checkSum, nil
+}
+
+// clientStmt builds the statement which streams the columns of all rows of a table matching the filter (all rows if empty).
+func (e *engine) clientStmt(table string, cols []column, filter string) string {
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, e.dia.quoteIdent(col.name))
+	}
+	return "select " + strings.Join(names, ", ") + " from " + e.source(table, filter)
+}
+
+// clientAggregate streams all rows of a table bucket and aggregates their row hashes in Go.
+// The column values are converted by the reference implementation into the same canonical strings as the canonical column expressions of the dialect do.
+func (e *engine) clientAggregate(ctx context.Context, q querier, table string, cols []column, b bucket) (checksum.Aggregate, error) {
+	agg := checksum.NewAggregate(hashAlgorithm, aggregation)
+
+	classes := make([]typeClass, 0, len(cols))
+	for _, col := range cols {
+		classes = append(classes, e.dia.typeClass(col.dataType))
+	}
+	stmt := e.clientStmt(table, cols, b.filter)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[3]: "+stmt)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := q.QueryContext(ctx, stmt)
+	if err != nil {
+		return agg, e.logError(err)
+	}
+	defer rowSet.Close()
+
+	values := make([]any, len(cols))
+	valuePtrs := make([]any, len(cols))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	canonicalColumns := make([]string, len(cols))
+	for rowSet.Next() {
+		if err = rowSet.Scan(valuePtrs...); err != nil {
+			return agg, e.logError(err)
+		}
+		for i, value := range values {
+			if canonicalColumns[i], err = hashAlgorithm.Canonical(classes[i], value); err != nil {
+				return agg, e.logError(fmt.Errorf("Table %s, column %s: %w", table, cols[i].name, err))
+			}
+		}
+		if err = agg.Add(hashAlgorithm.RowHash(canonicalColumns)); err != nil {
+			return agg, e.logError(err)
+		}
+	}
+	if err = rowSet.Err(); err != nil {
+		return agg, e.logError(err)
+	}
+
+	return agg, nil
+}
+
+// clientBucketAggregates streams all rows of a table matching the filter once and aggregates their row hashes in Go into
+// the hash buckets of the rows, see bucketAggregates.
+func (e *engine) clientBucketAggregates(ctx context.Context, q querier, table string, cols []column, filter string, hashCols []column, buckets int) (map[int]*checksum.Aggregate, error) {
+	aggs := make(map[int]*checksum.Aggregate)
+
+	allCols := append(slices.Clip(cols), hashCols...)
+	classes := make([]typeClass, 0, len(allCols))
+	for _, col := range allCols {
+		classes = append(classes, e.dia.typeClass(col.dataType))
+	}
+	stmt := e.clientStmt(table, allCols, filter)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[3]: "+stmt)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := q.QueryContext(ctx, stmt)
+	if err != nil {
+		return aggs, e.logError(err)
+	}
+	defer rowSet.Close()
+
+	values := make([]any, len(allCols))
+	valuePtrs := make([]any, len(allCols))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rowSet.Next() {
+		if err = rowSet.Scan(valuePtrs...); err != nil {
+			return aggs, e.logError(err)
+		}
+		rowHash, err := hashAlgorithm.Row(classes[:len(cols)], values[:len(cols)])
+		if err != nil {
+			return aggs, e.logError(fmt.Errorf("Table %s: %w", table, err))
+		}
+		hash := rowHash
+		if len(hashCols) > 0 {
+			if hash, err = hashAlgorithm.Row(classes[len(cols):], values[len(cols):]); err != nil {
+				return aggs, e.logError(fmt.Errorf("Table %s: %w", table, err))
+			}
+		}
+		number, err := hashBucket(hash, buckets)
+		if err != nil {
+			return aggs, e.logError(err)
+		}
+		agg, found := aggs[number]
+		if !found {
+			newAgg := checksum.NewAggregate(hashAlgorithm, aggregation)
+			agg = &newAgg
+			aggs[number] = agg
+		}
+		if err = agg.Add(rowHash); err != nil {
+			return aggs, e.logError(err)
+		}
+	}
+	if err = rowSet.Err(); err != nil {
+		return aggs, e.logError(err)
+	}
+
+	return aggs, nil
+}
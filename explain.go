@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+// explainTable writes the canonical column expressions and the checksum statements of a table to STDOUT, see the -explain option.
+// Only the column metadata of the table is queried, the checksum statements are not executed.
+// The output is a SQL script, all explanations are SQL comments.
+func (e *engine) explainTable(ctx context.Context, db *sql.DB, table string) error {
+	conn, err := e.openSession(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer e.closeSession(conn)
+
+	allCols, err := e.columns(ctx, conn, table)
+	if err != nil {
+		return err
+	}
+	if len(allCols) == 0 {
+		return e.logError(errors.New("Table " + table + " has no columns."))
+	}
+	cols, err := e.selectColumns(table, allCols)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{"-- " + e.cfg.instance + "." + table}
+	if e.cfg.mode == clientMode {
+		lines = append(lines, "-- Mode: client, the canonical strings and the row hashes are calculated by "+executableName)
+	}
+	for _, col := range cols {
+		line := "-- Column " + col.name + " (" + col.dataType + ")"
+		if e.cfg.mode != clientMode {
+			line += ": " + e.dia.canonicalExpr(e.dia.quoteIdent(col.name), e.dia.typeClass(col.dataType))
+		}
+		lines = append(lines, line)
+	}
+
+	opt := e.cfg.options(table)
+	switch {
+	case opt.buckets > 1 && opt.bucketKey != "":
+		keyName := e.dia.quoteIdent(opt.bucketKey)
+		for _, col := range allCols {
+			if strings.EqualFold(col.name, opt.bucketKey) {
+				keyName = e.dia.quoteIdent(col.name)
+			}
+		}
+		lines = append(lines, "-- The key ranges of the "+strconv.Itoa(opt.buckets)+" buckets are derived from the minimum and maximum key:", e.keyRangeStmt(table, keyName)+";")
+		lines = append(lines, "-- Every bucket is calculated by the following statement, <key range> is the key range of the bucket:", e.explainStmt(table, cols, bucket{number: 1, filter: "<key range>"})+";")
+	case opt.buckets > 1:
+		stmt := e.bucketSumsStmt(table, cols, "", nil, opt.buckets)
+		if e.cfg.mode == clientMode {
+			stmt = e.clientStmt(table, cols, "")
+		}
+		lines = append(lines, "-- All "+strconv.Itoa(opt.buckets)+" row hash buckets are calculated by the following statement, BUCKET is the bucket number minus 1:", stmt+";")
+	default:
+		lines = append(lines, e.explainStmt(table, cols, bucket{})+";")
+	}
+
+	simplelog.Write(simplelog.STDOUT, strings.Join(lines, "\n"))
+	return nil
+}
+
+// explainStmt returns the checksum statement of a table bucket, which is executed by tableChecksum.
+// The zero bucket is the whole table.
+func (e *engine) explainStmt(table string, cols []column, b bucket) string {
+	switch {
+	case e.cfg.mode == clientMode:
+		return e.clientStmt(table, cols, b.filter)
+	case b.number == 0 && aggregation == checksum.V1:
+		return e.checksumStmt(table, cols, "")
+	default:
+		return e.sumsStmt(table, cols, b)
+	}
+}
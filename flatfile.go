@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+// supported flat file formats
+const (
+	csvFormat     string = "csv"
+	parquetFormat string = "parquet"
+)
+
+// julianDayUnixEpoch is the Julian day of 1970-01-01, which is used by legacy INT96 Parquet timestamps.
+const julianDayUnixEpoch int64 = 2440588
+
+// collection of declared flat file column properties
+type fileColumn struct {
+	name  string
+	class typeClass
+}
+
+// fileDB checksums flat files (CSV or Parquet) in Go by using the reference implementation of package checksum.
+// A flat file doesn't require a database connection, thus openDB returns no database handle.
+type fileDB struct {
+	cfg         config
+	path        string // file name, can include the wildcards of filepath.Match, e.g. *
+	fileFormat  string // csv or parquet, derived from the file extension if not set
+	delimiter   string // CSV field delimiter
+	header      bool   // CSV files start with a header line
+	columnTypes string // comma separated list of column names and their data types
+}
+
+func (f *fileDB) instance() string {
+	return f.cfg.instance
+}
+
+func (f *fileDB) file() string {
+	return f.path
+}
+
+func (f *fileDB) logPrefix() string {
+	return "[" + f.instance() + "] -"
+}
+
+// format returns the format of a flat file.
+func (f *fileDB) format(file string) string {
+	if f.fileFormat != "" {
+		return strings.ToLower(f.fileFormat)
+	}
+	if strings.EqualFold(filepath.Ext(file), "."+parquetFormat) {
+		return parquetFormat
+	}
+	return csvFormat
+}
+
+// columns returns the declared columns in their canonical order.
+// The declaration is a comma separated list of column names and their data types, e.g. ID integer, CODE varchar(20), NUMVAL decimal(18,2)
+func (f *fileDB) columns() ([]fileColumn, error) {
+	var cols []fileColumn
+	var declaration strings.Builder
+	depth := 0
+	// split the declaration at commas, which are not part of a data type, e.g. decimal(18,2)
+	for _, r := range f.columnTypes + "," {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			if d := strings.TrimSpace(declaration.String()); d != "" {
+				name, dataType, ok := strings.Cut(d, " ")
+				if dataType = strings.TrimSpace(dataType); !ok || dataType == "" {
+					return cols, errors.New("Column " + name + " requires a data type.")
+				}
+				class, err := checksum.ParseClass(dataType)
+				if err != nil {
+					return cols, errors.New("Column " + name + ": " + err.Error())
+				}
+				cols = append(cols, fileColumn{name: name, class: class})
+			}
+			declaration.Reset()
+			continue
+		}
+		declaration.WriteRune(r)
+	}
+	return cols, nil
+}
+
+// ----------------------------------------------------------------------------
+func (f *fileDB) openDB(ctx context.Context, password string) (*sql.DB, error) {
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, f.logPrefix(), "Profile parameter:", "File:"+f.file()+",", "Columntypes:"+f.columnTypes)
+	return nil, nil
+}
+
+func (f *fileDB) closeDB(db *sql.DB) error {
+	return nil
+}
+
+func (f *fileDB) queryDB(ctx context.Context, db *sql.DB) error {
+	if pr.structure {
+		err := errors.New(formatMsg(mm047, f.instance()))
+		simplelog.Write(simplelog.MULTI, f.logPrefix(), err.Error())
+		return err
+	}
+	if pr.explain {
+		simplelog.Write(simplelog.STDOUT, "-- "+f.instance()+": flat files are checksummed by "+executableName+" without SQL statements")
+		return nil
+	}
+	files, err := filepath.Glob(f.file())
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, f.logPrefix(), err.Error())
+		return err
+	}
+	if len(files) == 0 {
+		err = errors.New("File " + f.file() + " could not be found.")
+		simplelog.Write(simplelog.MULTI, f.logPrefix(), err.Error())
+		return err
+	}
+	cols, err := f.columns()
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, f.logPrefix(), err.Error())
+		return err
+	}
+
+	// compile the checksum for all found files, the table name is the file name without extension
+	// If the run is interrupted, the current and all remaining files are reported as interrupted.
+	for _, file := range files {
+		agg := checksum.NewAggregate(hashAlgorithm, aggregation)
+		start := time.Now()
+		result := newResult(f.instance(), filepath.Dir(file), strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+		if errors.Is(err, errInterrupted) {
+			result.err = err
+			writeInterrupted(result)
+			continue
+		}
+		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, f.logPrefix(), "File:", file, "Format:", f.format(file))
+		if f.format(file) == parquetFormat {
+			err = f.parquetChecksum(ctx, file, cols, &agg)
+		} else {
+			err = f.csvChecksum(ctx, file, cols, &agg)
+		}
+		result.duration = time.Since(start)
+		if err = interrupted(ctx, err); errors.Is(err, errInterrupted) {
+			result.err = err
+			writeInterrupted(result)
+			continue
+		}
+		if err != nil {
+			err = fmt.Errorf("File %s: %w", file, err)
+			simplelog.Write(simplelog.MULTI, f.logPrefix(), err.Error())
+			result.err = err
+			addResult(result)
+			return err
+		}
+		result.numRows, result.checksum = int(agg.NumRows()), agg.Checksum()
+		writeChecksum(result)
+	}
+
+	return err
+}
+
+// addRow adds the canonical row hash of the column values of a row to the aggregate.
+func addRow(agg *checksum.Aggregate, cols []fileColumn, values []any) error {
+	canonicalColumns := make([]string, len(cols))
+	for i, col := range cols {
+		s, err := hashAlgorithm.Canonical(col.class, values[i])
+		if err != nil {
+			return fmt.Errorf("row %d, column %s: %w", agg.NumRows()+1, col.name, err)
+		}
+		canonicalColumns[i] = s
+	}
+	return agg.Add(hashAlgorithm.RowHash(canonicalColumns))
+}
+
+// csvChecksum aggregates all rows of a CSV file. Empty fields are NULL values.
+// Columns are assigned by the header line (case-insensitive) or by their position in case of a file without header.
+func (f *fileDB) csvChecksum(ctx context.Context, file string, cols []fileColumn, agg *checksum.Aggregate) error {
+	if len(cols) == 0 {
+		return errors.New("the Columntypes parameter is required for CSV files")
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	reader := csv.NewReader(fh)
+	reader.ReuseRecord = true
+	if f.delimiter != "" {
+		reader.Comma = []rune(f.delimiter)[0]
+	}
+
+	position := make([]int, len(cols))
+	for i := range cols {
+		position[i] = i
+	}
+	if f.header {
+		header, err := reader.Read()
+		if err != nil {
+			return err
+		}
+		for i, col := range cols {
+			position[i] = -1
+			for j, name := range header {
+				if strings.EqualFold(strings.TrimSpace(name), col.name) {
+					position[i] = j
+				}
+			}
+			if position[i] < 0 {
+				return errors.New("column " + col.name + " could not be found in the header")
+			}
+		}
+	}
+
+	values := make([]any, len(cols))
+	for {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for i, p := range position {
+			values[i] = nil
+			if p >= len(record) {
+				return fmt.Errorf("row %d: column %s is missing", agg.NumRows()+1, cols[i].name)
+			}
+			if record[p] != "" {
+				values[i] = record[p]
+			}
+		}
+		if err = addRow(agg, cols, values); err != nil {
+			return err
+		}
+	}
+}
+
+// parquetChecksum aggregates all rows of a Parquet file. Only flat schemas (no nested columns) are supported.
+// If no columns are declared, all columns are used and their type class is derived from the Parquet schema.
+func (f *fileDB) parquetChecksum(ctx context.Context, file string, cols []fileColumn, agg *checksum.Aggregate) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	stat, err := fh.Stat()
+	if err != nil {
+		return err
+	}
+	pf, err := parquet.OpenFile(fh, stat.Size())
+	if err != nil {
+		return err
+	}
+
+	fields := pf.Schema().Fields()
+	for _, field := range fields {
+		if !field.Leaf() || field.Repeated() {
+			return errors.New("nested column " + field.Name() + " is not supported")
+		}
+	}
+	position := make([]int, 0, len(fields))
+	if len(cols) == 0 {
+		for i, field := range fields {
+			cols = append(cols, fileColumn{name: field.Name(), class: parquetTypeClass(field.Type())})
+			position = append(position, i)
+		}
+	} else {
+		for _, col := range cols {
+			p := -1
+			for i, field := range fields {
+				if strings.EqualFold(field.Name(), col.name) {
+					p = i
+				}
+			}
+			if p < 0 {
+				return errors.New("column " + col.name + " could not be found in the schema")
+			}
+			position = append(position, p)
+		}
+	}
+
+	reader := parquet.NewReader(pf)
+	defer reader.Close()
+	rows := make([]parquet.Row, 128)
+	values := make([]any, len(cols))
+	for {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		n, readErr := reader.ReadRows(rows)
+		for _, row := range rows[:n] {
+			for i, p := range position {
+				if values[i], err = parquetValue(fields[p].Type(), row[p]); err != nil {
+					return fmt.Errorf("row %d, column %s: %w", agg.NumRows()+1, cols[i].name, err)
+				}
+			}
+			if err = addRow(agg, cols, values); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// parquetTypeClass derives the type class of a Parquet column from its logical and physical type.
+func parquetTypeClass(t parquet.Type) typeClass {
+	if lt := t.LogicalType(); lt != nil {
+		switch {
+		case lt.UTF8 != nil, lt.Enum != nil, lt.Json != nil:
+			return charType
+		case lt.Decimal != nil, lt.Integer != nil:
+			return decimalType
+		case lt.Date != nil:
+			return dateType
+		case lt.Timestamp != nil:
+			return timestampType
+		case lt.Time != nil:
+			return otherType
+		}
+	}
+	switch t.Kind() {
+	case parquet.Boolean:
+		return booleanType
+	case parquet.Int32, parquet.Int64:
+		return decimalType
+	case parquet.Int96:
+		return timestampType
+	case parquet.Float, parquet.Double:
+		return floatType
+	case parquet.ByteArray:
+		return charType
+	default:
+		return otherType
+	}
+}
+
+// parquetValue converts a Parquet value into a Go value according to the logical type of its column.
+func parquetValue(t parquet.Type, v parquet.Value) (any, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+	if lt := t.LogicalType(); lt != nil {
+		switch {
+		case lt.Decimal != nil:
+			var unscaled *big.Int
+			switch v.Kind() {
+			case parquet.Int32, parquet.Int64:
+				unscaled = big.NewInt(v.Int64())
+			default:
+				// big-endian two's complement
+				b := v.ByteArray()
+				unscaled = new(big.Int).SetBytes(b)
+				if len(b) > 0 && b[0]&0x80 != 0 {
+					unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+				}
+			}
+			return new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(lt.Decimal.Scale)), nil)).FloatString(int(lt.Decimal.Scale)), nil
+		case lt.Date != nil:
+			return time.Unix(int64(v.Int32())*86400, 0).UTC(), nil
+		case lt.Timestamp != nil:
+			return parquetTime(lt.Timestamp.Unit, v.Int64()).UTC(), nil
+		case lt.Time != nil:
+			if v.Kind() == parquet.Int32 {
+				return time.UnixMilli(int64(v.Int32())).UTC().Format("15:04:05.000000"), nil
+			}
+			return parquetTime(lt.Time.Unit, v.Int64()).UTC().Format("15:04:05.000000"), nil
+		}
+	}
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean(), nil
+	case parquet.Int32, parquet.Int64:
+		return v.Int64(), nil
+	case parquet.Int96:
+		i := v.Int96()
+		nanos := int64(uint64(i[1])<<32 | uint64(i[0]))
+		return time.Unix((int64(i[2])-julianDayUnixEpoch)*86400, nanos).UTC(), nil
+	case parquet.Float:
+		return v.Float(), nil
+	case parquet.Double:
+		return v.Double(), nil
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return string(v.ByteArray()), nil
+	default:
+		return nil, fmt.Errorf("unsupported Parquet type %s", v.Kind())
+	}
+}
+
+// parquetTime converts a Parquet time or timestamp value of the given unit into a time.
+func parquetTime(unit format.TimeUnit, value int64) time.Time {
+	switch {
+	case unit.Millis != nil:
+		return time.UnixMilli(value)
+	case unit.Nanos != nil:
+		return time.Unix(0, value)
+	default:
+		return time.UnixMicro(value)
+	}
+}
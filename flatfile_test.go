@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+)
+
+// t1ColumnTypes declares the columns of T1 in the order of the CREATE TABLE statements of the test data scripts.
+const t1ColumnTypes string = "ID integer, ID_DESC integer, INSERTED_DATE date, INSERTED_TS timestamp(6), CODE1 varchar(20), CODE2 char(8), NUMVAL1 integer, NUMVAL2 decimal(18,2), FLOATVAL1 double, ISVALID boolean"
+
+func TestFileColumns(t *testing.T) {
+	f := fileDB{columnTypes: t1ColumnTypes}
+	cols, err := f.columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fileColumn{{"ID", decimalType}, {"ID_DESC", decimalType}, {"INSERTED_DATE", dateType}, {"INSERTED_TS", timestampType},
+		{"CODE1", charType}, {"CODE2", charType}, {"NUMVAL1", decimalType}, {"NUMVAL2", decimalType}, {"FLOATVAL1", floatType}, {"ISVALID", booleanType}}
+	if len(cols) != len(want) {
+		t.Fatalf("got %d columns, want %d", len(cols), len(want))
+	}
+	for i := range want {
+		if cols[i] != want[i] {
+			t.Errorf("column %d: got %v, want %v", i+1, cols[i], want[i])
+		}
+	}
+
+	for _, columnTypes := range []string{"ID integer, CODE", "ID integer, CODE ", "ID integer, CODE blob"} {
+		f := fileDB{columnTypes: columnTypes}
+		if _, err := f.columns(); err == nil {
+			t.Errorf("columns of %q: want an error", columnTypes)
+		}
+	}
+}
+func TestParquetValue(t *testing.T) {
+	twosComplement := func(unscaled int64) []byte {
+		return binary.BigEndian.AppendUint64(nil, uint64(unscaled))
+	}
+	ts := time.Date(2012, 4, 9, 14, 3, 33, 727000000, time.UTC)
+	nanos := uint64(ts.Sub(ts.Truncate(24 * time.Hour)))
+	sinceMidnight := ts.Sub(ts.Truncate(24 * time.Hour))
+
+	tests := []struct {
+		name  string
+		typ   parquet.Type
+		value parquet.Value
+		want  any
+	}{
+		{"decimal", parquet.Decimal(2, 18, parquet.FixedLenByteArrayType(8)).Type(), parquet.FixedLenByteArrayValue(twosComplement(90)), "0.90"},
+		{"negative decimal", parquet.Decimal(2, 18, parquet.FixedLenByteArrayType(8)).Type(), parquet.FixedLenByteArrayValue(twosComplement(-76)), "-0.76"},
+		{"int32 decimal", parquet.Decimal(3, 9, parquet.Int32Type).Type(), parquet.Int32Value(-1500), "-1.500"},
+		{"int96 timestamp", parquet.Int96Type, parquet.Int96Value(deprecated.Int96{uint32(nanos), uint32(nanos >> 32), 2456027}), ts},
+		{"date", parquet.Date().Type(), parquet.Int32Value(15439), time.Date(2012, 4, 9, 0, 0, 0, 0, time.UTC)},
+		{"timestamp micros", parquet.Timestamp(parquet.Microsecond).Type(), parquet.Int64Value(ts.UnixMicro()), ts},
+		{"time millis", parquet.Time(parquet.Millisecond).Type(), parquet.Int32Value(int32(sinceMidnight.Milliseconds())), "14:03:33.727000"},
+		{"time micros", parquet.Time(parquet.Microsecond).Type(), parquet.Int64Value(sinceMidnight.Microseconds()), "14:03:33.727000"},
+		{"string", parquet.String().Type(), parquet.ByteArrayValue([]byte("asdf")), "asdf"},
+		{"null", parquet.String().Type(), parquet.NullValue(), nil},
+	}
+	for _, test := range tests {
+		got, err := parquetValue(test.typ, test.value)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if want, ok := test.want.(time.Time); ok {
+			if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(want) {
+				t.Errorf("%s: got %v, want %v", test.name, got, want)
+			}
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
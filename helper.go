@@ -94,7 +94,7 @@ func formatMsg(m string, p ...string) string {
 	result := m
 	for i, v := range p {
 		param := "%" + strconv.Itoa(i+1)
-		result = strings.Replace(m, param, v, -1)
+		result = strings.Replace(result, param, v, -1)
 
 	}
 	return result
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestFormatMsg(t *testing.T) {
+	tests := []struct {
+		msg    string
+		params []string
+		want   string
+	}{
+		{"no parameters", nil, "no parameters"},
+		{"instance %1", []string{"oracle.prod"}, "instance oracle.prod"},
+		{"%1 failed, retry %2 of %3", []string{"Connection", "1", "3"}, "Connection failed, retry 1 of 3"},
+		{"%2 before %1", []string{"a", "b"}, "b before a"},
+	}
+	for _, test := range tests {
+		if got := formatMsg(test.msg, test.params...); got != test.want {
+			t.Errorf("formatMsg(%q, %q) = %q, want %q", test.msg, test.params, got, test.want)
+		}
+	}
+}
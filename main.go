@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -14,7 +17,7 @@ import (
 // message catalog
 const (
 	mm000 string = "config file name"
-	mm001 string = "instance name\n  The defined format is <predefined DBMS name>.<instance ID>\n  Predefined DBMS names are: exasol, mysql, mssql, oracle, postgresql"
+	mm001 string = "instance name\n  The defined format is <predefined DBMS name>.<instance ID>\n  Predefined DBMS names are: db2, duckdb, exasol, file, mysql, mssql, oracle, postgresql, sqlite"
 	mm002 string = "password store command\n  init   - creates and initializes the password store and creates the secret key file\n  add    - adds a specified DBMS instance and its password to the password store\n  update - updates the password of the specified DBMS instance in the password store\n  delete - deletes the specified DBMS instance record from the password store\n  show   - shows all DBMS instances records saved in the password store\n  sync   - synchronizes the password store with the config file"
 	mm003 string = "log detail level: DEBUG (extended logging), TRACE (full logging)"
 	mm004 string = "to add instance credentials in the password store the command option '-i <instance name>' is required"
@@ -31,11 +34,64 @@ const (
 	mm016 string = "the password store specified by the Passwordstore parmeter does not exist"
 	mm017 string = "DBMS instance section '%1' does not contain an instance ID"
 	mm018 string = "remove instance %1 from the password store"
+	mm019 string = "DBMS instance section '%1' contains an unsupported Mode '%2', supported are: server, client"
+	mm020 string = "compare pair '%1' requires the Source and Target parameters"
+	mm021 string = "compare pair '%1' refers to the instance '%2', which is not configured or not active"
+	mm022 string = "the compare command requires at least one compare pair in the Compare section of the config file"
+	mm023 string = "unsupported command '%1' specified"
+	mm024 string = "compare  - compares the checksums of the table pairs of the Compare section\ndiff     - locates the missing, extra and changed rows of two tables, e.g. diff oracle.prod.TAB2 postgresql.new.tab2\nvalidate - validates the config file and reports all problems\ncheck    - checks the connectivity of all active instances and whether their tables and queries resolve"
+	mm025 string = "baseline file name\n  The checksums of all tables are recorded in the baseline file"
+	mm026 string = "baseline file name\n  The checksums of all tables are verified against the checksums recorded in the baseline file"
+	mm027 string = "the baseline file %1 is not written, because the checksum calculation of at least one instance failed or was interrupted"
+	mm028 string = "the baseline file %1 contains the invalid line: %2"
+	mm029 string = "output format: text, json, csv or junit"
+	mm030 string = "unsupported output format '%1' specified"
+	mm031 string = "the output format %1 is not supported by the compare, diff and check commands and the -verify option"
+	mm032 string = "the diff command requires a source and a target table (<instance name>.<table>) and the key columns specified by the option '-key <key columns>'"
+	mm033 string = "the table %1 doesn't refer to a configured and active instance"
+	mm034 string = "the diff command is not supported by the instance %1"
+	mm035 string = "comma separated list of key columns used by the diff command"
+	mm036 string = "the Buckets parameter of the table %2 of the instance %1 has to be a positive number: %3"
+	mm037 string = "the Parallelism parameter of the instance %1 has to be a positive number: %2"
+	mm038 string = "the Parallelism parameter of the common section has to be a positive number: %1"
+	mm039 string = "the Filter parameter of the table %2 of the instance %1 is invalid: %3"
+	mm040 string = "the query %2 of the instance %1 is invalid: %3"
+	mm041 string = "the mapping of the table %2 of the compare pair '%1' contains the invalid column mapping: %3"
+	mm042 string = "the table %1 is mapped to different column orders by the compare pairs"
+	mm043 string = "unsupported hash algorithm '%1' specified, supported are: md5, sha1, sha256"
+	mm044 string = "hash algorithm of the row hashes and checksums: md5, sha1 or sha256\n  Overrides the Hash parameter of the config file, the default is md5"
+	mm045 string = "the Aggregation parameter of the common section has to be 1 or 2: %1"
+	mm046 string = "checksum of the table definitions instead of the table data\n  The definition consists of the column names, ordinal positions, normalized data types, nullability and primary key"
+	mm047 string = "the -structure option is not supported by the instance %1"
+	mm048 string = "the -structure option is not supported by the diff command"
+	mm049 string = "explains the checksum calculation without executing it\n  The canonical column expressions and the checksum statements of all tables are written to STDOUT"
+	mm050 string = "the -explain option can't be combined with the compare and diff commands, the -baseline, -verify and -structure options and the structured output formats"
+	mm051 string = "unsupported keyword"
+	mm052 string = "the section doesn't contain any keywords"
+	mm053 string = "unsupported section, the predefined DBMS names are: Db2, Duckdb, Exasol, File, Mssql, Mysql, Oracle, Postgresql, Sqlite"
+	mm054 string = "the Active parameter has to be 0 or 1: %1"
+	mm055 string = "the keyword is mandatory for %1 instances"
+	mm056 string = "the Port parameter has to be a port number (1-65535): %1"
+	mm057 string = "unsupported Format '%1', supported are: csv, parquet"
+	mm058 string = "the Header parameter has to be 0 or 1: %1"
+	mm059 string = "the config file %1 is valid"
+	mm060 string = "the Timeout parameter of the common section has to be a positive duration, e.g. 90s, 30m or 2h: %1"
+	mm061 string = "the Statementtimeout parameter of the common section has to be a positive duration, e.g. 90s, 30m or 2h: %1"
+	mm062 string = "%1, the running statements are canceled"
+	mm063 string = "the Retries parameter of the common section has to be a non-negative number: %1"
+	mm064 string = "the Retries parameter of the instance %1 has to be a non-negative number: %2"
+	mm065 string = "the Retrybackoff parameter of the common section has to be a positive duration, e.g. 500ms, 5s or 1m: %1"
+	mm066 string = "the Retrybackoff parameter of the instance %1 has to be a positive duration, e.g. 500ms, 5s or 1m: %2"
+	mm067 string = "%1 failed by a transient error, retry %2 of %3 in %4"
+	mm068 string = "the baseline file %1 records the settings '%2', but the checksums are calculated with the settings '%3', thus they are not verified"
+	mm069 string = "the key column %1 could not be found in the table %2"
 )
 
 const (
-	md5Ok = iota
-	md5Error
+	md5Ok          = 0
+	md5Error       = 1 // the checksum calculation of at least one instance failed
+	md5Mismatch    = 2 // at least one compare pair doesn't match or a checksum differs from the baseline
+	md5Interrupted = 4 // the run was interrupted by a signal or the run timeout, not all tables have been checksummed
 )
 
 const (
@@ -57,6 +113,14 @@ type parameter struct {
 	instance      string
 	passwordStore string
 	logLevel      int
+	command       string
+	baseline      string
+	verify        string
+	output        string
+	key           string
+	hash          string
+	structure     bool
+	explain       bool
 }
 
 // command line parameter
@@ -69,7 +133,17 @@ func parseParameter() {
 	flag.StringVar(&pr.passwordStore, "p", "", mm002)
 	loglevelStr := ""
 	flag.StringVar(&loglevelStr, "l", "", mm003)
+	flag.StringVar(&pr.baseline, "baseline", "", mm025)
+	flag.StringVar(&pr.verify, "verify", "", mm026)
+	flag.StringVar(&pr.output, "o", textOutput, mm029)
+	flag.StringVar(&pr.key, "key", "", mm035)
+	flag.StringVar(&pr.hash, "hash", "", mm044)
+	flag.BoolVar(&pr.structure, "structure", false, mm046)
+	flag.BoolVar(&pr.explain, "explain", false, mm049)
+	flag.Usage = usage
 	flag.Parse()
+	pr.command = strings.ToLower(flag.Arg(0))
+	pr.output = strings.ToLower(pr.output)
 
 	// convert provided log level into integer
 	switch strings.ToUpper(loglevelStr) {
@@ -82,29 +156,77 @@ func parseParameter() {
 	}
 }
 
+// usage prints the usage of the commands and all command options.
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s: [options] [command]\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "  command\n    \t%s\n", strings.ReplaceAll(mm024, "\n", "\n    \t"))
+	flag.PrintDefaults()
+}
+
+// addInstanceError stores the error of an instance as result, if no table result contains the error.
+func addInstanceError(instance string, err error) {
+	if !hasError(instance) {
+		result := newResult(instance, "", "")
+		result.err = err
+		addResult(result)
+	}
+}
+
 // compileMD5TableSum encapsulates the workflow how to compile the MD5 checksum of a database table.
-func compileMD5TableSum(instance string, wg *sync.WaitGroup, result chan<- int) {
+// An interrupted instance returns md5Interrupted instead of md5Error.
+func compileMD5TableSum(ctx context.Context, instance string, wg *sync.WaitGroup, result chan<- int) {
 	defer wg.Done()
 
-	// open database connection
-	password := instancePassword[instance]
-	db, err := instanceName(instance).openDB(password)
+	// open database connection, the connection retries are part of the results of the instance
+	db, retries, err := openInstance(ctx, instance)
+	setConnectionRetries(instance, retries)
 	if err != nil {
-		result <- md5Error
+		err = interrupted(ctx, err)
+		addInstanceError(instance, err)
+		result <- instanceErrorCode(err)
 		return
 	}
 	// close database connection
 	defer instanceName(instance).closeDB(db)
 	// query database
-	err = instanceName(instance).queryDB(db)
+	err = instanceName(instance).queryDB(ctx, db)
 	if err != nil {
-		result <- md5Error
+		addInstanceError(instance, err)
+		result <- instanceErrorCode(err)
 		return
 	}
 	// success
 	result <- md5Ok
 }
 
+// instanceErrorCode returns the return code of a failed instance.
+func instanceErrorCode(err error) int {
+	if errors.Is(err, errInterrupted) {
+		return md5Interrupted
+	}
+	return md5Error
+}
+
+// compileInstances compiles the MD5 table checksums of the given DBMS instances concurrently and returns the overall return code.
+func compileInstances(ctx context.Context, instances []string) int {
+	var rc int
+	var wg sync.WaitGroup
+
+	rcGoRoutines := make(chan int, len(instances))
+	for _, k := range instances {
+		wg.Add(1)
+		go compileMD5TableSum(ctx, k, &wg, rcGoRoutines)
+	}
+	wg.Wait()
+	close(rcGoRoutines)
+
+	// calculate overall return code
+	for rcSingle := range rcGoRoutines {
+		rc |= rcSingle
+	}
+	return rc
+}
+
 // run is the entry point of the application logic.
 func run() int {
 	var rc int
@@ -129,6 +251,55 @@ func run() int {
 	simplelog.Write(simplelog.FILE, "Configfile:", cfgPath)
 	simplelog.Write(simplelog.FILE, "Passwordstore:", passwordStoreFile)
 	simplelog.Write(simplelog.FILE, "Passwordstorekey:", passwordStoreKeyFile)
+	simplelog.Write(simplelog.FILE, "Hash algorithm:", hashAlgorithm)
+	simplelog.Write(simplelog.FILE, "Aggregation:", aggregation)
+	simplelog.Write(simplelog.FILE, "Structure:", pr.structure)
+	simplelog.Write(simplelog.FILE, "Explain:", pr.explain)
+	simplelog.Write(simplelog.FILE, "Timeout:", runTimeout)
+	simplelog.Write(simplelog.FILE, "Statement timeout:", statementTimeout)
+
+	// check for a supported command
+	if pr.command != "" && pr.command != compareCommand && pr.command != diffCommand && pr.command != validateCommand && pr.command != checkCommand {
+		simplelog.Write(simplelog.MULTI, formatMsg(mm023, pr.command))
+		simplelog.Write(simplelog.FILE, "Return Code: "+strconv.Itoa(md5Error))
+		return md5Error
+	}
+
+	// the config file has been validated by setupEnv already
+	if pr.command == validateCommand {
+		simplelog.Write(simplelog.MULTI, formatMsg(mm059, cfgPath))
+		simplelog.Write(simplelog.FILE, "Return Code: "+strconv.Itoa(md5Ok))
+		return md5Ok
+	}
+
+	// the diff command compares table data only
+	if pr.command == diffCommand && pr.structure {
+		simplelog.Write(simplelog.MULTI, mm048)
+		simplelog.Write(simplelog.FILE, "Return Code: "+strconv.Itoa(md5Error))
+		return md5Error
+	}
+
+	// the -explain option only writes the checksum statements
+	if pr.explain && (pr.command != "" || pr.baseline != "" || pr.verify != "" || pr.structure || pr.output != textOutput) {
+		simplelog.Write(simplelog.MULTI, mm050)
+		simplelog.Write(simplelog.FILE, "Return Code: "+strconv.Itoa(md5Error))
+		return md5Error
+	}
+
+	// check for a supported output format
+	switch pr.output {
+	case textOutput:
+	case jsonOutput, csvOutput, junitOutput:
+		if pr.command == compareCommand || pr.command == diffCommand || pr.command == checkCommand || pr.verify != "" {
+			simplelog.Write(simplelog.MULTI, formatMsg(mm031, pr.output))
+			simplelog.Write(simplelog.FILE, "Return Code: "+strconv.Itoa(md5Error))
+			return md5Error
+		}
+	default:
+		simplelog.Write(simplelog.MULTI, formatMsg(mm030, pr.output))
+		simplelog.Write(simplelog.FILE, "Return Code: "+strconv.Itoa(md5Error))
+		return md5Error
+	}
 
 	// check for password store command
 	if pr.passwordStore != "" {
@@ -192,19 +363,48 @@ func run() int {
 			simplelog.Write(simplelog.MULTI, err.Error())
 			rc = md5Error
 		} else {
-			var wg sync.WaitGroup
-			// compile MD5 table checksum for all active DBMS instances
-			rcGoRoutines := make(chan int, len(instanceActive))
-			for k := range instanceActive {
-				wg.Add(1)
-				go compileMD5TableSum(k, &wg, rcGoRoutines)
+			// SIGINT, SIGTERM and the run timeout cancel the running statements of all instances
+			ctx, stop := runContext()
+			defer stop()
+
+			switch pr.command {
+			case compareCommand:
+				simplelog.Write(simplelog.FILE, "Command:", pr.command)
+				rc = compareTables(ctx)
+			case diffCommand:
+				simplelog.Write(simplelog.FILE, "Command:", pr.command)
+				rc = diffTables(ctx, flag.Args()[1:])
+			case checkCommand:
+				simplelog.Write(simplelog.FILE, "Command:", pr.command)
+				rc = checkInstances(ctx)
+			default:
+				// compile MD5 table checksum for all active DBMS instances
+				instances := make([]string, 0, len(instanceActive))
+				for k := range instanceActive {
+					instances = append(instances, k)
+				}
+				rc = compileInstances(ctx, instances)
+			}
+
+			if err := writeResults(pr.output); err != nil {
+				simplelog.Write(simplelog.MULTI, err.Error())
+				rc |= md5Error
 			}
-			wg.Wait()
-			close(rcGoRoutines)
 
-			// calculate overall return code
-			for rcSingle := range rcGoRoutines {
-				rc |= rcSingle
+			// verify the checksums against a recorded baseline and record a new baseline
+			if pr.verify != "" {
+				simplelog.Write(simplelog.FILE, "Verify baseline:", pr.verify)
+				rc |= verifyBaseline(pr.verify)
+			}
+			if pr.baseline != "" {
+				if rc&(md5Error|md5Interrupted) != 0 {
+					simplelog.Write(simplelog.MULTI, formatMsg(mm027, pr.baseline))
+				} else if err := writeBaseline(pr.baseline); err != nil {
+					simplelog.Write(simplelog.MULTI, err.Error())
+					rc |= md5Error
+				} else {
+					simplelog.Write(simplelog.FILE, "Baseline written:", pr.baseline)
+				}
 			}
 		}
 	}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+// TestMain starts the log service, which is required by the engine. The log file is written to a temporary directory
+// and the results aren't written to STDOUT.
+func TestMain(m *testing.M) {
+	logDir, err := os.MkdirTemp("", "md5tabsum")
+	if err != nil {
+		panic(err)
+	}
+	simplelog.Startup(100)
+	simplelog.SetupLog(filepath.Join(logDir, "md5tabsum.log"), false)
+	pr.output = jsonOutput
+
+	rc := m.Run()
+	simplelog.Shutdown(false)
+	os.RemoveAll(logDir)
+	os.Exit(rc)
+}
+
+// goldenChecksums returns the checksums of T1 of a hash algorithm per aggregation version. The V1 checksum is the
+// checksum of the golden file of package checksum, the other versions are aggregated from its golden row hashes.
+func goldenChecksums(t *testing.T, h checksum.Hash) map[checksum.Version]string {
+	t.Helper()
+	file := filepath.Join("checksum", "testdata", "T1.golden")
+	if h != checksum.MD5 {
+		file = filepath.Join("checksum", "testdata", "T1."+h.String()+".golden")
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) < 2 {
+		t.Fatalf("%s: no golden row hashes", file)
+	}
+
+	checksums := map[checksum.Version]string{checksum.V1: lines[len(lines)-1]}
+	agg := checksum.NewAggregate(h, checksum.V2)
+	for _, rowHash := range lines[:len(lines)-1] {
+		if err = agg.Add(rowHash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checksums[checksum.V2] = agg.Checksum()
+	return checksums
+}
+
+// t1Instance is a test instance, whose table T1 is created by the test data script testdata/<DBMS name>.sql.
+// The table T1 of a flat file instance is its file.
+type t1Instance struct {
+	driver     string                    // database driver, empty for flat files
+	dsn        string                    // DSN of an embedded database
+	dsnEnv     string                    // environment variable of the DSN of a DBMS server, the test is skipped if it isn't set
+	schemaStmt string                    // statement querying the current schema, if the DBMS has no default schema
+	database   func(cfg config) database // creates the instance of a config
+}
+
+// t1Instances are the test instances of TestT1 by instance name. The instances of DBMS, whose drivers require a build
+// tag, are added by the init function of a test file with the same build tag.
+var t1Instances = map[string]t1Instance{
+	"sqlite.test": {driver: "sqlite", dsn: "file:t1?mode=memory&cache=shared", database: func(cfg config) database { return &sqliteDB{cfg: cfg} }},
+	// the CSV export of T1 has a header line, whose columns are in reverse order, and NULL values are empty fields
+	"file.csv": {database: func(cfg config) database {
+		return &fileDB{cfg: cfg, path: filepath.Join("testdata", "T1.csv"), header: true, columnTypes: t1ColumnTypes}
+	}},
+	// the Parquet export of T1 stores the timestamps as INT96 and the decimals as two's complement FIXED_LEN_BYTE_ARRAY
+	"file.parquet": {database: func(cfg config) database {
+		return &fileDB{cfg: cfg, path: filepath.Join("testdata", "T1.parquet"), columnTypes: t1ColumnTypes}
+	}},
+}
+
+// open opens the database of a test instance and executes the test data script of its DBMS. The table T1 is dropped
+// and the database is closed at the end of the test. It returns the schema of T1.
+func (i t1Instance) open(t *testing.T, instance string) (*sql.DB, string) {
+	t.Helper()
+	if i.driver == "" {
+		return nil, ""
+	}
+	dsn := i.dsn
+	if i.dsnEnv != "" {
+		if dsn = os.Getenv(i.dsnEnv); dsn == "" {
+			t.Skip(i.dsnEnv + " is not set")
+		}
+	}
+	dbms, _, _ := strings.Cut(instance, ".")
+	script, err := os.ReadFile(filepath.Join("testdata", dbms+".sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(i.driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// some drivers execute a single statement only
+	for _, stmt := range strings.Split(string(script), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			if _, err = db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	t.Cleanup(func() { db.Exec("drop table T1") })
+
+	var schema string
+	if i.schemaStmt != "" {
+		if err = db.QueryRow(i.schemaStmt).Scan(&schema); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, strings.TrimSpace(schema)
+}
+
+// TestT1 compiles the checksum of the table T1 of all test instances in server and client mode for every hash algorithm
+// and aggregation version, without and with row hash buckets, and compares its result with the golden checksum of T1.
+func TestT1(t *testing.T) {
+	defer func(h checksum.Hash, v checksum.Version) { hashAlgorithm, aggregation = h, v }(hashAlgorithm, aggregation)
+
+	for _, instance := range slices.Sorted(maps.Keys(t1Instances)) {
+		t.Run(instance, func(t *testing.T) {
+			db, schema := t1Instances[instance].open(t, instance)
+			modes, bucketCounts := []string{serverMode, clientMode}, []int{1, 3}
+			if t1Instances[instance].driver == "" {
+				// flat files are always checksummed by md5tabsum and can't be split into buckets
+				modes, bucketCounts = modes[:1], bucketCounts[:1]
+			}
+			for _, h := range []checksum.Hash{checksum.MD5, checksum.SHA1, checksum.SHA256} {
+				for v, want := range goldenChecksums(t, h) {
+					for _, mode := range modes {
+						for _, buckets := range bucketCounts {
+							t.Run(h.String()+"/"+v.String()+"/"+mode+"/"+strconv.Itoa(buckets), func(t *testing.T) {
+								hashAlgorithm, aggregation = h, v
+								clear(tableResults)
+								cfg := config{instance: instance, schema: schema, table: []string{"T1"}, mode: mode, parallelism: 1,
+									tableOpt: map[string]tableOptions{"T1": {buckets: buckets}}}
+								if err := t1Instances[instance].database(cfg).queryDB(context.Background(), db); err != nil {
+									t.Fatal(err)
+								}
+								checkT1(t, instance, want, buckets)
+							})
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// checkT1 compares the stored result of T1 of an instance with the golden checksum and checks that the rows of T1 are
+// distributed over the given number of buckets.
+func checkT1(t *testing.T, instance, want string, buckets int) {
+	t.Helper()
+	results := tableResults[instance]
+	if len(results) != 1 || !strings.EqualFold(results[0].table, "T1") {
+		t.Fatalf("got %d results, want the result of T1", len(results))
+	}
+	if results[0].numRows != 13 || results[0].checksum != want {
+		t.Errorf("got %d rows and checksum %s, want 13 rows and checksum %s", results[0].numRows, results[0].checksum, want)
+	}
+	if buckets == 1 {
+		return
+	}
+	var numRows int
+	for _, b := range results[0].buckets {
+		numRows += b.numRows
+	}
+	if len(results[0].buckets) != buckets || numRows != 13 {
+		t.Errorf("got %d buckets of %d rows, want %d buckets of 13 rows", len(results[0].buckets), numRows, buckets)
+	}
+}
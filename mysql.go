@@ -1,166 +1,193 @@
-package main
-
-import (
-	"database/sql"
-	"errors"
-	"fmt"
-	"strconv"
-	"strings"
-
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/sabitor/simplelog"
-)
-
-type mysqlDB struct {
-	cfg config
-}
-
-func (m *mysqlDB) instance() string {
-	return m.cfg.instance
-}
-
-func (m *mysqlDB) host() string {
-	return m.cfg.host
-}
-
-func (m *mysqlDB) port() int {
-	return m.cfg.port
-}
-
-func (m *mysqlDB) user() string {
-	return m.cfg.user
-}
-
-func (m *mysqlDB) schema() string {
-	return m.cfg.schema
-}
-
-func (m *mysqlDB) table() []string {
-	return m.cfg.table
-}
-
-func (m *mysqlDB) logPrefix() string {
-	return "[" + m.instance() + "] -"
-}
-
-// ----------------------------------------------------------------------------
-func (m *mysqlDB) openDB(password string) (*sql.DB, error) {
-	sqlMode := "ANSI_QUOTES"
-	tableFilter := strings.Join(m.table(), ", ")
-	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, m.logPrefix(), "Profile parameter:", "Host:"+m.host()+",", "Port:"+strconv.Itoa(m.port())+",", "User:"+m.user()+",", "Schema:"+m.schema()+",", "Table:"+tableFilter)
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?sql_mode=%s", m.user(), password, m.host(), m.port(), m.schema(), sqlMode)
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		simplelog.Write(simplelog.MULTI, m.logPrefix(), err.Error())
-		return db, err
-	}
-	return db, err
-}
-
-func (m *mysqlDB) closeDB(db *sql.DB) error {
-	return db.Close()
-}
-
-func (m *mysqlDB) queryDB(db *sql.DB) error {
-	var rowSet *sql.Rows
-	var tableNames []string
-	var err error
-
-	// PREPARE: Filter for all existing DB tables based on the configured table parameter (the tables parameter can include placeholders, e.g. %)
-	for _, table := range m.table() {
-		sqlPreparedStmt := "select TABLE_NAME from INFORMATION_SCHEMA.TABLES where TABLE_SCHEMA=? and TABLE_NAME like ?"
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, m.logPrefix(), "SQL[1]: "+sqlPreparedStmt, "-", "TABLE_SCHEMA:"+m.schema()+",", "TABLE_NAME:"+table)
-		rowSet, err = db.Query(sqlPreparedStmt, m.schema(), table)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, m.logPrefix(), err.Error())
-			return err
-		}
-		foundTable := ""
-		for rowSet.Next() {
-			// table exists in DB schema
-			err := rowSet.Scan(&foundTable)
-			if err != nil {
-				simplelog.Write(simplelog.MULTI, m.logPrefix(), err.Error())
-				return err
-			}
-			tableNames = append(tableNames, foundTable)
-		}
-		if foundTable == "" {
-			// table doesn't exist in the DB schema
-			err = errors.New("Table " + table + " could not be found.")
-			simplelog.Write(simplelog.MULTI, m.logPrefix(), err.Error())
-			return err
-		}
-	}
-
-	// EXECUTE: Compile MD5 for all found tables
-	maxChar := 65535
-	for _, table := range tableNames {
-		sqlPreparedStmt := "select COLUMN_NAME, DATA_TYPE, ORDINAL_POSITION from INFORMATION_SCHEMA.COLUMNS where TABLE_SCHEMA=? and TABLE_NAME=? order by ORDINAL_POSITION asc"
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, m.logPrefix(), "SQL[2]: "+sqlPreparedStmt, "-", "TABLE_SCHEMA:"+m.schema()+",", "TABLE_NAME:"+table)
-		rowSet, err = db.Query(sqlPreparedStmt, m.schema(), table)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, m.logPrefix(), err.Error())
-			return err
-		}
-
-		var columnNames, column, columnType string
-		var ordinalPosition int
-
-		// gather table properties
-		for rowSet.Next() {
-			if columnNames != "" {
-				columnNames += ", "
-			}
-			err := rowSet.Scan(&column, &columnType, &ordinalPosition)
-			if err != nil {
-				simplelog.Write(simplelog.MULTI, m.logPrefix(), err.Error())
-				return err
-			}
-
-			// convert all columns into string data type
-			if strings.Contains(strings.ToUpper(columnType), "CHAR") {
-				// calculate the MD5 of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
-				columnNames += "coalesce(md5(" + column + "), 'null')"
-			} else if strings.Contains(strings.ToUpper(columnType), "DECIMAL") {
-				columnNames += "coalesce(cast(trim(TRAILING '0' from " + column + ") as char(" + strconv.Itoa(maxChar) + ")), 'null')"
-			} else if strings.Contains(strings.ToUpper(columnType), "TIME") || strings.Contains(strings.ToUpper(columnType), "DATE") {
-				columnNames += "coalesce(date_format(" + column + ", '%Y-%m-%d %H:%i:%s.%f'), 'null')"
-			} else {
-				columnNames += "coalesce(cast(" + column + " as char(" + strconv.Itoa(maxChar) + ")), 'null')"
-			}
-
-			simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, m.logPrefix(), "Column", ordinalPosition, "of "+table+":", column, "("+columnType+")")
-		}
-		if ordinalPosition > 1 {
-			// table contains more than one column - concatenate them
-			columnNames = "concat(" + columnNames + ")"
-		}
-
-		// compile MD5 (d41d8cd98f00b204e9800998ecf8427e is the default result for an empty table) by using the following SQL:
-		//   select count(1) NUMROWS,
-		//          coalesce(md5(concat(sum(cast(conv(substring(ROWHASH, 1, 8), 16, 10) as unsigned)),
-		//                              sum(cast(conv(substring(ROWHASH, 9, 8), 16, 10) as unsigned)),
-		//                              sum(cast(conv(substring(ROWHASH, 17, 8), 16, 10) as unsigned)),
-		//                              sum(cast(conv(substring(ROWHASH, 25, 8), 16, 10) as unsigned)))),
-		//                   'd41d8cd98f00b204e9800998ecf8427e') CHECKSUM
-		//   from (select md5(%s) ROWHASH from %s.%s) t
-		sqlText := "select count(1) NUMROWS, coalesce(md5(concat(sum(cast(conv(substring(ROWHASH, 1, 8), 16, 10) as unsigned)), sum(cast(conv(substring(ROWHASH, 9, 8), 16, 10) as unsigned)), sum(cast(conv(substring(ROWHASH, 17, 8), 16, 10) as unsigned)), sum(cast(conv(substring(ROWHASH, 25, 8), 16, 10) as unsigned)))), 'd41d8cd98f00b204e9800998ecf8427e') CHECKSUM from (select md5(%s) ROWHASH from %s.%s) t"
-		sqlQueryStmt := fmt.Sprintf(sqlText, columnNames, m.schema(), table)
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, m.logPrefix(), "SQL[3]: "+sqlQueryStmt)
-
-		var numTableRows int
-		var checkSum string
-		err = db.QueryRow(sqlQueryStmt).Scan(&numTableRows, &checkSum)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, m.logPrefix(), err.Error())
-			return err
-		}
-		simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, m.logPrefix(), "Table:"+table+",", "Number of rows:", numTableRows)
-
-		simplelog.Write(simplelog.STDOUT, fmt.Sprintf("%s:%s", m.instance()+"."+table, checkSum))
-		simplelog.Write(simplelog.FILE, m.logPrefix(), "Table:"+table+",", "MD5: "+checkSum)
-	}
-
-	return err
-}
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+type mysqlDB struct {
+	cfg config
+}
+
+func (m *mysqlDB) instance() string {
+	return m.cfg.instance
+}
+
+func (m *mysqlDB) host() string {
+	return m.cfg.host
+}
+
+func (m *mysqlDB) port() int {
+	return m.cfg.port
+}
+
+func (m *mysqlDB) user() string {
+	return m.cfg.user
+}
+
+func (m *mysqlDB) schema() string {
+	return m.cfg.schema
+}
+
+func (m *mysqlDB) table() []string {
+	return m.cfg.table
+}
+
+func (m *mysqlDB) logPrefix() string {
+	return "[" + m.instance() + "] -"
+}
+
+// ----------------------------------------------------------------------------
+func (m *mysqlDB) openDB(ctx context.Context, password string) (*sql.DB, error) {
+	sqlMode := "ANSI_QUOTES"
+	tableFilter := strings.Join(m.table(), ", ")
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, m.logPrefix(), "Profile parameter:", "Host:"+m.host()+",", "Port:"+strconv.Itoa(m.port())+",", "User:"+m.user()+",", "Schema:"+m.schema()+",", "Table:"+tableFilter)
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?sql_mode=%s", m.user(), password, m.host(), m.port(), m.schema(), sqlMode)
+	db, err := connectDB(ctx, "mysql", dsn)
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, m.logPrefix(), err.Error())
+		return db, err
+	}
+	return db, err
+}
+
+func (m *mysqlDB) closeDB(db *sql.DB) error {
+	return db.Close()
+}
+
+func (m *mysqlDB) queryDB(ctx context.Context, db *sql.DB) error {
+	return m.checksumEngine().run(ctx, db)
+}
+
+func (m *mysqlDB) checksumEngine() *engine {
+	return newEngine(&m.cfg, m)
+}
+
+// ----------------------------------------------------------------------------
+// dialect
+
+func (m *mysqlDB) sessionStmt() []string {
+	return nil
+}
+
+func (m *mysqlDB) versionStmt() string {
+	return "select version(), current_user()"
+}
+
+func (m *mysqlDB) transientErrors() []string {
+	// error 1213 deadlock, 1205 lock wait timeout, 1040 too many connections and lost connections of the driver
+	return []string{"Error 1213", "Error 1205", "Error 1040", "invalid connection"}
+}
+
+func (m *mysqlDB) tableStmt(schema, table string) (string, []any) {
+	return "select TABLE_NAME from INFORMATION_SCHEMA.TABLES where TABLE_SCHEMA=? and TABLE_NAME like ?", []any{schema, table}
+}
+
+func (m *mysqlDB) columnStmt(schema, table string) (string, []any) {
+	return "select COLUMN_NAME, DATA_TYPE, ORDINAL_POSITION from INFORMATION_SCHEMA.COLUMNS where TABLE_SCHEMA=? and TABLE_NAME=? order by ORDINAL_POSITION asc", []any{schema, table}
+}
+
+func (m *mysqlDB) structureStmt(schema, table string) (string, []any) {
+	// Hint: BOOLEAN columns are TINYINT(1) columns.
+	return infoSchemaStructureStmt("case when c.COLUMN_TYPE='tinyint(1)' then 'boolean' else c.DATA_TYPE end", "?", "?"), []any{schema, table}
+}
+
+// Hint: The session runs with sql_mode ANSI_QUOTES, thus identifiers are quoted by double quotes.
+func (m *mysqlDB) quoteIdent(ident string) string {
+	return "\"" + strings.ReplaceAll(ident, "\"", "\"\"") + "\""
+}
+
+func (m *mysqlDB) quoteLiteral(literal string) string {
+	// Hint: Backslashes are escape characters in MySQL string literals.
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(literal, "\\", "\\\\"), "'", "''") + "'"
+}
+
+func (m *mysqlDB) timestampLiteral(value time.Time, class typeClass) string {
+	if class == dateType {
+		return "date '" + value.Format("2006-01-02") + "'"
+	}
+	return "timestamp '" + value.Format(checksum.TimestampLayout) + "'"
+}
+
+func (m *mysqlDB) typeClass(columnType string) typeClass {
+	columnType = strings.ToUpper(columnType)
+	switch {
+	case strings.Contains(columnType, "CHAR"):
+		return charType
+	case strings.Contains(columnType, "DECIMAL"):
+		return decimalType
+	case strings.Contains(columnType, "TIME"), strings.Contains(columnType, "DATE"):
+		return timestampType
+	default:
+		return otherType
+	}
+}
+
+func (m *mysqlDB) canonicalExpr(column string, class typeClass) string {
+	maxChar := 65535
+	switch class {
+	case charType:
+		// calculate the hash of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
+		return "coalesce(" + m.hashExpr("rtrim("+column+")") + ", 'null')"
+	case decimalType:
+		return "coalesce(cast(trim(TRAILING '0' from " + column + ") as char(" + strconv.Itoa(maxChar) + ")), 'null')"
+	case timestampType:
+		return "coalesce(date_format(" + column + ", '%Y-%m-%d %H:%i:%s.%f'), 'null')"
+	default:
+		return "coalesce(cast(" + column + " as char(" + strconv.Itoa(maxChar) + ")), 'null')"
+	}
+}
+
+func (m *mysqlDB) concatExpr(columns []string) string {
+	if len(columns) > 1 {
+		// table contains more than one column - concatenate them
+		return "concat(" + strings.Join(columns, ", ") + ")"
+	}
+	return strings.Join(columns, "")
+}
+
+// hashExpr returns the hash (lowercase hex digits) of a string expression.
+func (m *mysqlDB) hashExpr(expr string) string {
+	switch hashAlgorithm {
+	case checksum.SHA1:
+		return "sha1(" + expr + ")"
+	case checksum.SHA256:
+		return "sha2(" + expr + ", 256)"
+	default:
+		return "md5(" + expr + ")"
+	}
+}
+
+func (m *mysqlDB) rowHashExpr(row string) string {
+	return m.hashExpr(row)
+}
+
+// hexToIntExpr converts 8 hex digits of a hash expression, starting at the given position, into an unsigned integer.
+func (m *mysqlDB) hexToIntExpr(hash string, start int) string {
+	return "cast(conv(substring(" + hash + ", " + strconv.Itoa(start) + ", 8), 16, 10) as unsigned)"
+}
+
+func (m *mysqlDB) partSumExpr(start int) string {
+	return "cast(sum(" + m.hexToIntExpr("ROWHASH", start) + ") as char)"
+}
+
+func (m *mysqlDB) partXorExpr(start int) string {
+	return "cast(bit_xor(" + m.hexToIntExpr("ROWHASH", start) + ") as char)"
+}
+
+func (m *mysqlDB) bucketExpr(hash string, buckets int) string {
+	return "mod(" + m.hexToIntExpr(hash, 1) + ", " + strconv.Itoa(buckets) + ")"
+}
+
+func (m *mysqlDB) checksumExpr() string {
+	return "count(1) NUMROWS, coalesce(" + m.hashExpr("concat("+strings.Join(partSumExprs(m), ", ")+")") + ", '" + hashAlgorithm.Empty() + "') CHECKSUM"
+}
@@ -1,182 +1,205 @@
-package main
-
-import (
-	"database/sql"
-	"errors"
-	"fmt"
-	"strconv"
-	"strings"
-
-	"github.com/sabitor/simplelog"
-	go_ora "github.com/sijms/go-ora/v2"
-)
-
-type oracleDB struct {
-	cfg config
-	srv string // Oracle specific
-}
-
-func (o *oracleDB) instance() string {
-	return o.cfg.instance
-}
-
-func (o *oracleDB) host() string {
-	return o.cfg.host
-}
-
-func (o *oracleDB) port() int {
-	return o.cfg.port
-}
-
-func (o *oracleDB) user() string {
-	return o.cfg.user
-}
-
-func (o *oracleDB) schema() string {
-	return o.cfg.schema
-}
-
-func (o *oracleDB) table() []string {
-	return o.cfg.table
-}
-
-func (o *oracleDB) service() string {
-	return o.srv
-}
-
-func (o *oracleDB) logPrefix() string {
-	return "[" + o.instance() + "] -"
-}
-
-// ----------------------------------------------------------------------------
-func (o *oracleDB) openDB(password string) (*sql.DB, error) {
-	// urlOptions := map[string]string{
-	// 	"trace file": "trace.log",
-	// }
-
-	tableFilter := strings.Join(o.table(), ", ")
-	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, o.logPrefix(), "DBHost:"+o.host(), "Port:"+strconv.Itoa(o.port()), "Service:"+o.service(), "User:"+o.user(), "Schema:"+o.schema(), "Table: "+tableFilter)
-	dsn := go_ora.BuildUrl(o.host(), o.port(), o.service(), o.user(), password /* urlOptions */, nil)
-	db, err := sql.Open("oracle", dsn)
-	if err != nil {
-		simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
-		return db, err
-	}
-	return db, err
-}
-
-func (o *oracleDB) closeDB(db *sql.DB) error {
-	return db.Close()
-}
-
-func (o *oracleDB) queryDB(db *sql.DB) error {
-	var rowSet *sql.Rows
-	var tableNames []string
-	var err error
-
-	// set '.' as NUMBER/FLOAT decimal point for this session
-	_, err = db.Exec("alter session set NLS_NUMERIC_CHARACTERS = '.,'")
-	if err != nil {
-		simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
-		return err
-	}
-
-	// PREPARE: filter for all existing DB tables based on the configured table parameter (the tables parameter can include placeholders, e.g. %)
-	for _, table := range o.table() {
-		// Hint: Prepared statements are currently not supported by go-ora. Thus, the command will be build by using the real filter values instead of using place holders.
-		sqlPreparedStmt := "select TABLE_NAME from ALL_TABLES where OWNER='" + strings.ToUpper(o.schema()) + "' and TABLE_NAME like '" + strings.ToUpper(table) + "'"
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, o.logPrefix(), "SQL[1]: "+sqlPreparedStmt)
-		rowSet, err = db.Query(sqlPreparedStmt)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
-			return err
-		}
-		foundTable := ""
-		for rowSet.Next() {
-			// table exists in DB schema
-			err := rowSet.Scan(&foundTable)
-			if err != nil {
-				simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
-				return err
-			}
-			tableNames = append(tableNames, foundTable)
-		}
-		if foundTable == "" {
-			// table doesn't exist in the DB schema
-			err = errors.New("Table " + table + " could not be found.")
-			simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
-			return err
-		}
-	}
-
-	// EXECUTE: compile MD5 for all found tables
-	max := 4000
-	for _, table := range tableNames {
-		sqlPreparedStmt := "select COLUMN_NAME, DATA_TYPE || '(' || DATA_LENGTH || ',' || coalesce(to_char(DATA_PRECISION), 'na') || ',' || coalesce(to_char(DATA_SCALE), 'na') || ')' as DATA_TYPE, COLUMN_ID from ALL_TAB_COLS where OWNER='" + strings.ToUpper(o.schema()) + "' and TABLE_NAME='" + strings.ToUpper(table) + "' order by COLUMN_ID asc"
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, o.logPrefix(), "SQL[2]: "+sqlPreparedStmt)
-		rowSet, err = db.Query(sqlPreparedStmt)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
-			return err
-		}
-
-		var columnNames, column, columnType string
-		var columnID int
-
-		// gather table properties
-		for rowSet.Next() {
-			if columnNames != "" {
-				columnNames += " || "
-			}
-			err := rowSet.Scan(&column, &columnType, &columnID)
-			if err != nil {
-				simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
-				return err
-			}
-
-			// convert all columns into string data type
-			if strings.Contains(strings.ToUpper(columnType), "CHAR") {
-				// calculate the MD5 of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
-				columnNames += "case when \"" + column + "\" is NULL then 'null' else cast(lower(standard_hash(trim(trailing ' ' from \"" + column + "\"), 'MD5')) as varchar2(4000)) end"
-			} else if strings.Contains(strings.ToUpper(columnType), "DATE") {
-				columnNames += "coalesce(to_char(\"" + column + "\", 'YYYY-MM-DD HH24:MI:SS')||'.000000', 'null')"
-			} else if strings.Contains(strings.ToUpper(columnType), "TIME") {
-				columnNames += "coalesce(to_char(\"" + column + "\", 'YYYY-MM-DD HH24:MI:SS.FF6'), 'null')"
-			} else if strings.Contains(strings.ToUpper(columnType), "NUMBER") || strings.Contains(strings.ToUpper(columnType), "FLOAT") {
-				// Hint: Numbers with a leading 0 require special handling (numbers between -1 and 1).
-				//       to_char or cast to varchar removes leading 0 from numbers, e.g. 0.123 becomes .123 or -0.123 becomes -.123
-				columnNames += "coalesce(case when \"" + column + "\" < 1 and \"" + column + "\" > -1 then rtrim(to_char(\"" + column + "\", 'FM0.9999999999999999999999999'), '.') else to_char(\"" + column + "\") end, 'null')"
-			} else {
-				columnNames += "coalesce(cast(\"" + column + "\" as varchar2(" + strconv.Itoa(max) + ")), 'null')"
-			}
-
-			simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, o.logPrefix(), "Column", columnID, "of "+table+":", column, "("+columnType+")")
-		}
-
-		// compile MD5 (d41d8cd98f00b204e9800998ecf8427e is the default result for an empty table) by using the following SQL:
-		//   select /*+ PARALLEL */
-		//          count(1) NUMROWS,
-		//          lower(cast(standard_hash(sum(to_number(substr(t.rowhash, 1, 8), 'xxxxxxxx')) ||
-		//                                   sum(to_number(substr(t.rowhash, 9, 8), 'xxxxxxxx')) ||
-		//                                   sum(to_number(substr(t.rowhash, 17, 8), 'xxxxxxxx')) ||
-		//                                   sum(to_number(substr(t.rowhash, 25, 8), 'xxxxxxxx')), 'MD5') as varchar(4000))) CHECKSUM
-		//   from (select standard_hash(%s, 'MD5') ROWHASH from %s.%s) t
-		sqlText := "select /*+ PARALLEL */ count(1) NUMROWS, lower(cast(standard_hash(sum(to_number(substr(t.rowhash, 1, 8), 'xxxxxxxx')) || sum(to_number(substr(t.rowhash, 9, 8), 'xxxxxxxx')) || sum(to_number(substr(t.rowhash, 17, 8), 'xxxxxxxx')) || sum(to_number(substr(t.rowhash, 25, 8), 'xxxxxxxx')), 'MD5') as varchar(4000))) CHECKSUM from (select standard_hash(%s, 'MD5') ROWHASH from %s.%s) t"
-		sqlQueryStmt := fmt.Sprintf(sqlText, columnNames, o.schema(), table)
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, o.logPrefix(), "SQL[3]: "+sqlQueryStmt)
-
-		var numTableRows int
-		var checkSum string
-		err = db.QueryRow(sqlQueryStmt).Scan(&numTableRows, &checkSum)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
-			return err
-		}
-		simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, o.logPrefix(), "Table:"+table+",", "Number of rows:", numTableRows)
-
-		simplelog.Write(simplelog.STDOUT, fmt.Sprintf("%s:%s", o.instance()+"."+table, checkSum))
-		simplelog.Write(simplelog.FILE, o.logPrefix(), "Table:"+table+",", "MD5: "+checkSum)
-	}
-
-	return err
-}
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sabitor/simplelog"
+	go_ora "github.com/sijms/go-ora/v2"
+
+	"md5tabsum/checksum"
+)
+
+type oracleDB struct {
+	cfg config
+	srv string // Oracle specific
+}
+
+func (o *oracleDB) instance() string {
+	return o.cfg.instance
+}
+
+func (o *oracleDB) host() string {
+	return o.cfg.host
+}
+
+func (o *oracleDB) port() int {
+	return o.cfg.port
+}
+
+func (o *oracleDB) user() string {
+	return o.cfg.user
+}
+
+func (o *oracleDB) schema() string {
+	return o.cfg.schema
+}
+
+func (o *oracleDB) table() []string {
+	return o.cfg.table
+}
+
+func (o *oracleDB) service() string {
+	return o.srv
+}
+
+func (o *oracleDB) logPrefix() string {
+	return "[" + o.instance() + "] -"
+}
+
+// ----------------------------------------------------------------------------
+func (o *oracleDB) openDB(ctx context.Context, password string) (*sql.DB, error) {
+	// urlOptions := map[string]string{
+	// 	"trace file": "trace.log",
+	// }
+
+	tableFilter := strings.Join(o.table(), ", ")
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, o.logPrefix(), "DBHost:"+o.host(), "Port:"+strconv.Itoa(o.port()), "Service:"+o.service(), "User:"+o.user(), "Schema:"+o.schema(), "Table: "+tableFilter)
+	dsn := go_ora.BuildUrl(o.host(), o.port(), o.service(), o.user(), password /* urlOptions */, nil)
+	db, err := connectDB(ctx, "oracle", dsn)
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, o.logPrefix(), err.Error())
+		return db, err
+	}
+	return db, err
+}
+
+func (o *oracleDB) closeDB(db *sql.DB) error {
+	return db.Close()
+}
+
+func (o *oracleDB) queryDB(ctx context.Context, db *sql.DB) error {
+	return o.checksumEngine().run(ctx, db)
+}
+
+func (o *oracleDB) checksumEngine() *engine {
+	return newEngine(&o.cfg, o)
+}
+
+// ----------------------------------------------------------------------------
+// dialect
+
+func (o *oracleDB) sessionStmt() []string {
+	// set '.' as NUMBER/FLOAT decimal point for this session
+	return []string{"alter session set NLS_NUMERIC_CHARACTERS = '.,'"}
+}
+
+func (o *oracleDB) versionStmt() string {
+	return "select PRODUCT || ' ' || VERSION, USER from PRODUCT_COMPONENT_VERSION where PRODUCT like 'Oracle%' and ROWNUM = 1"
+}
+
+func (o *oracleDB) transientErrors() []string {
+	// ORA-00060 deadlock, ORA-03113/ORA-03114/ORA-03135 lost connection, ORA-12170/ORA-12537/ORA-12541/ORA-12571 network errors
+	return []string{"ORA-00060", "ORA-03113", "ORA-03114", "ORA-03135", "ORA-12170", "ORA-12537", "ORA-12541", "ORA-12571"}
+}
+
+// Hint: Prepared statements are currently not supported by go-ora. Thus, the commands will be build by using the real filter values instead of using place holders.
+func (o *oracleDB) tableStmt(schema, table string) (string, []any) {
+	return "select TABLE_NAME from ALL_TABLES where OWNER=" + o.quoteLiteral(strings.ToUpper(schema)) + " and TABLE_NAME like " + o.quoteLiteral(strings.ToUpper(table)), nil
+}
+
+func (o *oracleDB) columnStmt(schema, table string) (string, []any) {
+	return "select COLUMN_NAME, DATA_TYPE || '(' || DATA_LENGTH || ',' || coalesce(to_char(DATA_PRECISION), 'na') || ',' || coalesce(to_char(DATA_SCALE), 'na') || ')' as DATA_TYPE, COLUMN_ID from ALL_TAB_COLS where OWNER=" + o.quoteLiteral(strings.ToUpper(schema)) + " and TABLE_NAME=" + o.quoteLiteral(strings.ToUpper(table)) + " order by COLUMN_ID asc", nil
+}
+
+func (o *oracleDB) structureStmt(schema, table string) (string, []any) {
+	owner, name := o.quoteLiteral(strings.ToUpper(schema)), o.quoteLiteral(strings.ToUpper(table))
+	return "select c.COLUMN_NAME, c.DATA_TYPE, c.CHAR_LENGTH, c.DATA_PRECISION, c.DATA_SCALE, c.COLUMN_ID, case c.NULLABLE when 'Y' then 1 else 0 end, " +
+		"coalesce((select k.POSITION from ALL_CONSTRAINTS p join ALL_CONS_COLUMNS k on k.OWNER=p.OWNER and k.CONSTRAINT_NAME=p.CONSTRAINT_NAME " +
+		"where p.CONSTRAINT_TYPE='P' and p.OWNER=c.OWNER and p.TABLE_NAME=c.TABLE_NAME and k.COLUMN_NAME=c.COLUMN_NAME), 0) " +
+		"from ALL_TAB_COLS c where c.OWNER=" + owner + " and c.TABLE_NAME=" + name + " order by c.COLUMN_ID asc", nil
+}
+
+// quoteLiteral quotes a string literal.
+func (o *oracleDB) quoteLiteral(literal string) string {
+	return "'" + strings.ReplaceAll(literal, "'", "''") + "'"
+}
+
+func (o *oracleDB) timestampLiteral(value time.Time, class typeClass) string {
+	// Hint: An explicit format is independent of the NLS_DATE_FORMAT and NLS_TIMESTAMP_FORMAT of the session.
+	//       Oracle dates contain the time of the day.
+	if class == dateType {
+		return "to_date('" + value.Format("2006-01-02 15:04:05") + "', 'YYYY-MM-DD HH24:MI:SS')"
+	}
+	return "to_timestamp('" + value.Format(checksum.TimestampLayout) + "', 'YYYY-MM-DD HH24:MI:SS.FF6')"
+}
+
+func (o *oracleDB) quoteIdent(ident string) string {
+	return "\"" + strings.ReplaceAll(ident, "\"", "\"\"") + "\""
+}
+
+func (o *oracleDB) typeClass(columnType string) typeClass {
+	columnType = strings.ToUpper(columnType)
+	switch {
+	case strings.Contains(columnType, "CHAR"):
+		return charType
+	case strings.Contains(columnType, "DATE"):
+		return dateType
+	case strings.Contains(columnType, "TIME"):
+		return timestampType
+	case strings.Contains(columnType, "NUMBER"), strings.Contains(columnType, "FLOAT"):
+		return decimalType
+	default:
+		return otherType
+	}
+}
+
+func (o *oracleDB) canonicalExpr(column string, class typeClass) string {
+	switch class {
+	case charType:
+		// calculate the hash of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
+		return "case when " + column + " is NULL then 'null' else cast(lower(" + o.hashExpr("trim(trailing ' ' from "+column+")") + ") as varchar2(4000)) end"
+	case dateType:
+		return "coalesce(to_char(" + column + ", 'YYYY-MM-DD HH24:MI:SS')||'.000000', 'null')"
+	case timestampType:
+		return "coalesce(to_char(" + column + ", 'YYYY-MM-DD HH24:MI:SS.FF6'), 'null')"
+	case decimalType:
+		// Hint: Numbers with a leading 0 require special handling (numbers between -1 and 1).
+		//       to_char or cast to varchar removes leading 0 from numbers, e.g. 0.123 becomes .123 or -0.123 becomes -.123
+		return "coalesce(case when " + column + " < 1 and " + column + " > -1 then rtrim(to_char(" + column + ", 'FM0.9999999999999999999999999'), '.') else to_char(" + column + ") end, 'null')"
+	default:
+		return "coalesce(cast(" + column + " as varchar2(4000)), 'null')"
+	}
+}
+
+func (o *oracleDB) concatExpr(columns []string) string {
+	return strings.Join(columns, " || ")
+}
+
+// hashExpr returns the hash (RAW) of a string expression.
+func (o *oracleDB) hashExpr(expr string) string {
+	algorithm := map[checksum.Hash]string{checksum.MD5: "MD5", checksum.SHA1: "SHA1", checksum.SHA256: "SHA256"}[hashAlgorithm]
+	return "standard_hash(" + expr + ", '" + algorithm + "')"
+}
+
+// rowHashExpr returns the row hash as lowercase hex digits like all other dialects, because standard_hash returns a RAW value,
+// which would be fetched as bytes.
+func (o *oracleDB) rowHashExpr(row string) string {
+	return "lower(rawtohex(" + o.hashExpr(row) + "))"
+}
+
+// hexToIntExpr converts 8 hex digits of a hash expression, starting at the given position, into a number.
+func (o *oracleDB) hexToIntExpr(hash string, start int) string {
+	return "to_number(substr(" + hash + ", " + strconv.Itoa(start) + ", 8), 'xxxxxxxx')"
+}
+
+func (o *oracleDB) partSumExpr(start int) string {
+	return "to_char(sum(" + o.hexToIntExpr("t.rowhash", start) + "))"
+}
+
+func (o *oracleDB) partXorExpr(start int) string {
+	div := func(a, b string) string { return "floor(" + a + " / " + b + ")" }
+	mod := func(a, b string) string { return "mod(" + a + ", " + b + ")" }
+	return "to_char(" + bitXorExpr(o.hexToIntExpr("t.rowhash", start), div, mod) + ")"
+}
+
+func (o *oracleDB) bucketExpr(hash string, buckets int) string {
+	return "mod(" + o.hexToIntExpr(hash, 1) + ", " + strconv.Itoa(buckets) + ")"
+}
+
+func (o *oracleDB) checksumExpr() string {
+	return "/*+ PARALLEL */ count(1) NUMROWS, coalesce(lower(cast(" + o.hashExpr(strings.Join(partSumExprs(o), " || ")) + " as varchar(4000))), '" + hashAlgorithm.Empty() + "') CHECKSUM"
+}
@@ -1,168 +1,196 @@
-package main
-
-import (
-	"database/sql"
-	"errors"
-	"fmt"
-	"strconv"
-	"strings"
-
-	_ "github.com/lib/pq"
-	"github.com/sabitor/simplelog"
-)
-
-type postgresqlDB struct {
-	cfg config
-	db  string // Postgresql specific
-}
-
-func (p *postgresqlDB) instance() string {
-	return p.cfg.instance
-}
-
-func (p *postgresqlDB) host() string {
-	return p.cfg.host
-}
-
-func (p *postgresqlDB) port() int {
-	return p.cfg.port
-}
-
-func (p *postgresqlDB) user() string {
-	return p.cfg.user
-}
-
-func (p *postgresqlDB) schema() string {
-	return p.cfg.schema
-}
-
-func (p *postgresqlDB) table() []string {
-	return p.cfg.table
-}
-
-func (p *postgresqlDB) database() string {
-	return p.db
-}
-
-func (p *postgresqlDB) logPrefix() string {
-	return "[" + p.instance() + "] -"
-}
-
-// ----------------------------------------------------------------------------
-func (p *postgresqlDB) openDB(password string) (*sql.DB, error) {
-	tableFilter := strings.Join(p.table(), ", ")
-	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, p.logPrefix(), "Profile parameter:", "Host:"+p.host()+",", "Port:"+strconv.Itoa(p.port())+",", "Database:"+p.database()+",", "User:"+p.user()+",", "Schema:"+p.schema()+",", "Table:"+tableFilter)
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", p.host(), p.port(), p.user(), password, p.database())
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		simplelog.Write(simplelog.MULTI, p.logPrefix(), err.Error())
-		return db, err
-	}
-	return db, err
-}
-
-func (p *postgresqlDB) closeDB(db *sql.DB) error {
-	return db.Close()
-}
-
-func (p *postgresqlDB) queryDB(db *sql.DB) error {
-	var rowSet *sql.Rows
-	var tableNames []string
-	var err error
-
-	// PREPARE: filter for all existing DB tables based on the configured table parameter (the tables parameter can include placeholders, e.g. %)
-	for _, table := range p.table() {
-		sqlPreparedStmt := "select TABLE_NAME from INFORMATION_SCHEMA.TABLES where TABLE_SCHEMA=$1 and TABLE_NAME like $2"
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, p.logPrefix(), "SQL[1]: "+sqlPreparedStmt, "-", "TABLE_SCHEMA:"+p.schema()+",", "TABLE_NAME:"+table)
-		rowSet, err = db.Query(sqlPreparedStmt, p.schema(), table)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, p.logPrefix(), err.Error())
-			return err
-		}
-		foundTable := ""
-		for rowSet.Next() {
-			// table exists in DB schema
-			err := rowSet.Scan(&foundTable)
-			if err != nil {
-				simplelog.Write(simplelog.MULTI, p.logPrefix(), err.Error())
-				return err
-			}
-			tableNames = append(tableNames, foundTable)
-		}
-		if foundTable == "" {
-			// table doesn't exist in the DB schema
-			err = errors.New("Table " + table + " could not be found.")
-			simplelog.Write(simplelog.MULTI, p.logPrefix(), err.Error())
-			return err
-		}
-	}
-
-	// EXECUTE: compile MD5 for all found tables
-	for _, table := range tableNames {
-		// FUTURE: In case of coltype VARCHAR the max length is not yet listed. This can be done by integrating the 'character_maximum_length' column in the 'information_scheam.columns' select statement.
-		sqlPreparedStmt := "select COLUMN_NAME, DATA_TYPE, ORDINAL_POSITION from INFORMATION_SCHEMA.COLUMNS where TABLE_SCHEMA=$1 and TABLE_NAME=$2 order by ORDINAL_POSITION asc"
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, p.logPrefix(), "SQL[2]: "+sqlPreparedStmt, "-", "TABLE_SCHEMA:"+p.schema()+",", "TABLE_NAME:"+table)
-		rowSet, err = db.Query(sqlPreparedStmt, p.schema(), table)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, p.logPrefix(), err.Error())
-			return err
-		}
-
-		var columnNames, column, columnType string
-		var ordinalPosition int
-
-		// gather table properties
-		for rowSet.Next() {
-			if columnNames != "" {
-				columnNames += " || "
-			}
-			err := rowSet.Scan(&column, &columnType, &ordinalPosition)
-			if err != nil {
-				simplelog.Write(simplelog.MULTI, p.logPrefix(), err.Error())
-				return err
-			}
-
-			// convert all columns into string data type
-			if strings.Contains(strings.ToUpper(columnType), "CHAR") {
-				// calculate the MD5 of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
-				columnNames += "coalesce(md5(" + column + "), 'null')"
-			} else if strings.Contains(strings.ToUpper(columnType), "NUMERIC") {
-				columnNames += "coalesce(trim_scale(" + column + ")::text, 'null')"
-			} else if strings.Contains(strings.ToUpper(columnType), "TIME") || strings.Contains(strings.ToUpper(columnType), "DATE") {
-				columnNames += "coalesce(to_char(" + column + ", 'YYYY-MM-DD HH24:MI:SS.US'), 'null')"
-			} else if strings.Contains(strings.ToUpper(columnType), "BOOLEAN") {
-				columnNames += "coalesce(" + column + "::integer::text, 'null')"
-			} else {
-				columnNames += "coalesce(" + column + "::text, 'null')"
-			}
-
-			simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, p.logPrefix(), "Column", ordinalPosition, "of "+table+":", column, "("+columnType+")")
-		}
-
-		// compile MD5 (d41d8cd98f00b204e9800998ecf8427e is the default result for an empty table) by using the following SQL:
-		//   select count(1) NUMROWS,
-		//          coalesce(md5(sum(('x' || substring(ROWHASH, 1, 8))::bit(32)::bigint)::text ||
-		//                       sum(('x' || substring(ROWHASH, 9, 8))::bit(32)::bigint)::text ||
-		//                       sum(('x' || substring(ROWHASH, 17, 8))::bit(32)::bigint)::text ||
-		//                       sum(('x' || substring(ROWHASH, 25, 8))::bit(32)::bigint)::text),
-		//                   'd41d8cd98f00b204e9800998ecf8427e') CHECKSUM
-		//   from (select md5(%s) ROWHASH from %s.%s) t
-		sqlText := "select count(1) NUMROWS, coalesce(md5(sum(('x' || substring(ROWHASH, 1, 8))::bit(32)::bigint)::text || sum(('x' || substring(ROWHASH, 9, 8))::bit(32)::bigint)::text ||sum(('x' || substring(ROWHASH, 17, 8))::bit(32)::bigint)::text || sum(('x' || substring(ROWHASH, 25, 8))::bit(32)::bigint)::text), 'd41d8cd98f00b204e9800998ecf8427e') CHECKSUM from (select md5(%s) ROWHASH from %s.%s) t"
-		sqlQueryStmt := fmt.Sprintf(sqlText, columnNames, p.schema(), table)
-		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, p.logPrefix(), "SQL[3]: "+sqlQueryStmt)
-
-		var numTableRows int
-		var checkSum string
-		err = db.QueryRow(sqlQueryStmt).Scan(&numTableRows, &checkSum)
-		if err != nil {
-			simplelog.Write(simplelog.MULTI, p.logPrefix(), err.Error())
-			return err
-		}
-		simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, p.logPrefix(), "Table:"+table+",", "Number of rows:", numTableRows)
-
-		simplelog.Write(simplelog.STDOUT, fmt.Sprintf("%s:%s", p.instance()+"."+table, checkSum))
-		simplelog.Write(simplelog.FILE, p.logPrefix(), "Table:"+table+",", "MD5: "+checkSum)
-	}
-
-	return err
-}
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+type postgresqlDB struct {
+	cfg config
+	db  string // Postgresql specific
+}
+
+func (p *postgresqlDB) instance() string {
+	return p.cfg.instance
+}
+
+func (p *postgresqlDB) host() string {
+	return p.cfg.host
+}
+
+func (p *postgresqlDB) port() int {
+	return p.cfg.port
+}
+
+func (p *postgresqlDB) user() string {
+	return p.cfg.user
+}
+
+func (p *postgresqlDB) schema() string {
+	return p.cfg.schema
+}
+
+func (p *postgresqlDB) table() []string {
+	return p.cfg.table
+}
+
+func (p *postgresqlDB) database() string {
+	return p.db
+}
+
+func (p *postgresqlDB) logPrefix() string {
+	return "[" + p.instance() + "] -"
+}
+
+// ----------------------------------------------------------------------------
+func (p *postgresqlDB) openDB(ctx context.Context, password string) (*sql.DB, error) {
+	tableFilter := strings.Join(p.table(), ", ")
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, p.logPrefix(), "Profile parameter:", "Host:"+p.host()+",", "Port:"+strconv.Itoa(p.port())+",", "Database:"+p.database()+",", "User:"+p.user()+",", "Schema:"+p.schema()+",", "Table:"+tableFilter)
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", p.host(), p.port(), p.user(), password, p.database())
+	db, err := connectDB(ctx, "postgres", dsn)
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, p.logPrefix(), err.Error())
+		return db, err
+	}
+	return db, err
+}
+
+func (p *postgresqlDB) closeDB(db *sql.DB) error {
+	return db.Close()
+}
+
+func (p *postgresqlDB) queryDB(ctx context.Context, db *sql.DB) error {
+	return p.checksumEngine().run(ctx, db)
+}
+
+func (p *postgresqlDB) checksumEngine() *engine {
+	return newEngine(&p.cfg, p)
+}
+
+// ----------------------------------------------------------------------------
+// dialect
+
+func (p *postgresqlDB) sessionStmt() []string {
+	return nil
+}
+
+func (p *postgresqlDB) versionStmt() string {
+	return "select version(), current_user"
+}
+
+func (p *postgresqlDB) transientErrors() []string {
+	// SQLSTATE 40P01 deadlock, 40001 serialization failure, 57P01 administrator shutdown, 57P03 startup and 53300 too many connections
+	return []string{"deadlock detected", "could not serialize access", "terminating connection", "the database system is", "too many clients"}
+}
+
+func (p *postgresqlDB) tableStmt(schema, table string) (string, []any) {
+	return "select TABLE_NAME from INFORMATION_SCHEMA.TABLES where TABLE_SCHEMA=$1 and TABLE_NAME like $2", []any{schema, table}
+}
+
+// FUTURE: In case of coltype VARCHAR the max length is not yet listed. This can be done by integrating the 'character_maximum_length' column in the 'information_scheam.columns' select statement.
+func (p *postgresqlDB) columnStmt(schema, table string) (string, []any) {
+	return "select COLUMN_NAME, DATA_TYPE, ORDINAL_POSITION from INFORMATION_SCHEMA.COLUMNS where TABLE_SCHEMA=$1 and TABLE_NAME=$2 order by ORDINAL_POSITION asc", []any{schema, table}
+}
+
+func (p *postgresqlDB) structureStmt(schema, table string) (string, []any) {
+	return infoSchemaStructureStmt("c.DATA_TYPE", "$1", "$2"), []any{schema, table}
+}
+
+func (p *postgresqlDB) quoteIdent(ident string) string {
+	return "\"" + strings.ReplaceAll(ident, "\"", "\"\"") + "\""
+}
+
+func (p *postgresqlDB) quoteLiteral(literal string) string {
+	return "'" + strings.ReplaceAll(literal, "'", "''") + "'"
+}
+
+func (p *postgresqlDB) timestampLiteral(value time.Time, class typeClass) string {
+	if class == dateType {
+		return "date '" + value.Format("2006-01-02") + "'"
+	}
+	return "timestamp '" + value.Format(checksum.TimestampLayout) + "'"
+}
+
+func (p *postgresqlDB) typeClass(columnType string) typeClass {
+	columnType = strings.ToUpper(columnType)
+	switch {
+	case strings.Contains(columnType, "CHAR"):
+		return charType
+	case strings.Contains(columnType, "NUMERIC"):
+		return decimalType
+	case strings.Contains(columnType, "TIME"), strings.Contains(columnType, "DATE"):
+		return timestampType
+	case strings.HasPrefix(columnType, "BOOL"): // BOOL is the data type name of the driver
+		return booleanType
+	default:
+		return otherType
+	}
+}
+
+func (p *postgresqlDB) canonicalExpr(column string, class typeClass) string {
+	switch class {
+	case charType:
+		// calculate the hash of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
+		return "coalesce(" + p.hashExpr("rtrim("+column+")") + ", 'null')"
+	case decimalType:
+		return "coalesce(trim_scale(" + column + ")::text, 'null')"
+	case timestampType:
+		return "coalesce(to_char(" + column + ", 'YYYY-MM-DD HH24:MI:SS.US'), 'null')"
+	case booleanType:
+		return "coalesce(" + column + "::integer::text, 'null')"
+	default:
+		return "coalesce(" + column + "::text, 'null')"
+	}
+}
+
+func (p *postgresqlDB) concatExpr(columns []string) string {
+	return strings.Join(columns, " || ")
+}
+
+// hashExpr returns the hash (lowercase hex digits) of a text expression.
+// Hint: SHA-1 requires the pgcrypto extension, SHA-256 is built-in since PostgreSQL 11.
+func (p *postgresqlDB) hashExpr(expr string) string {
+	switch hashAlgorithm {
+	case checksum.SHA1:
+		return "encode(digest(" + expr + ", 'sha1'), 'hex')"
+	case checksum.SHA256:
+		return "encode(sha256(convert_to(" + expr + ", 'UTF8')), 'hex')"
+	default:
+		return "md5(" + expr + ")"
+	}
+}
+
+func (p *postgresqlDB) rowHashExpr(row string) string {
+	return p.hashExpr(row)
+}
+
+// hexToIntExpr converts 8 hex digits of a hash expression, starting at the given position, into a BIGINT.
+func (p *postgresqlDB) hexToIntExpr(hash string, start int) string {
+	return "('x' || substring(" + hash + ", " + strconv.Itoa(start) + ", 8))::bit(32)::bigint"
+}
+
+func (p *postgresqlDB) partSumExpr(start int) string {
+	return "sum(" + p.hexToIntExpr("ROWHASH", start) + ")::text"
+}
+
+// Hint: The bit_xor aggregate function requires PostgreSQL 14 or later.
+func (p *postgresqlDB) partXorExpr(start int) string {
+	return "bit_xor(" + p.hexToIntExpr("ROWHASH", start) + ")::text"
+}
+
+func (p *postgresqlDB) bucketExpr(hash string, buckets int) string {
+	return "mod(" + p.hexToIntExpr(hash, 1) + ", " + strconv.Itoa(buckets) + ")"
+}
+
+func (p *postgresqlDB) checksumExpr() string {
+	return "count(1) NUMROWS, coalesce(" + p.hashExpr(strings.Join(partSumExprs(p), " || ")) + ", '" + hashAlgorithm.Empty() + "') CHECKSUM"
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sabitor/simplelog"
+
+	"md5tabsum/checksum"
+)
+
+// supported output formats
+const (
+	textOutput  string = "text"  // <instance>.<table>:<checksum> per table
+	jsonOutput  string = "json"  // JSON array of all table results
+	csvOutput   string = "csv"   // CSV file including a header line
+	junitOutput string = "junit" // JUnit XML, one test suite per instance and one test case per table
+)
+
+// collection of table checksum results
+// A result without table is the result of an instance, which failed before any table could be checksummed.
+type tableResult struct {
+	instance string
+	dbms     string
+	schema   string
+	table    string
+	numRows  int
+	checksum string
+	duration time.Duration
+	buckets  []bucketResult // checksums of the buckets, if the table is split into buckets
+	retries  int            // number of retries after transient errors including the connection retries of the instance
+	err      error
+}
+
+// skippedStatus is written instead of the checksum of a table, which wasn't started because another table of the instance failed.
+const skippedStatus string = "SKIPPED"
+
+// errSkipped is the error of a table, which wasn't started because another table of the instance failed.
+var errSkipped = errors.New("skipped, because another table of the instance failed")
+
+var (
+	tableResults      = make(map[string][]tableResult) // store the table results of all instances
+	connectionRetries = make(map[string]int)           // store the number of connection retries of all instances
+	tableResultsMu    sync.Mutex
+)
+
+// newResult creates a table result of an instance, the DBMS is derived from the instance name.
+// The retries of the result start with the connection retries of the instance.
+func newResult(instance, schema, table string) tableResult {
+	tableResultsMu.Lock()
+	defer tableResultsMu.Unlock()
+	dbms, _, _ := strings.Cut(instance, ".")
+	return tableResult{instance: instance, dbms: dbms, schema: schema, table: table, retries: connectionRetries[instance]}
+}
+
+// setConnectionRetries stores the number of connection retries of an instance.
+func setConnectionRetries(instance string, retries int) {
+	tableResultsMu.Lock()
+	defer tableResultsMu.Unlock()
+	connectionRetries[instance] = retries
+}
+
+// addResult stores a table result.
+func addResult(result tableResult) {
+	tableResultsMu.Lock()
+	defer tableResultsMu.Unlock()
+	tableResults[result.instance] = append(tableResults[result.instance], result)
+}
+
+// hasError returns true if an error result of an instance is already stored.
+func hasError(instance string) bool {
+	tableResultsMu.Lock()
+	defer tableResultsMu.Unlock()
+	for _, result := range tableResults[instance] {
+		if result.err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeChecksum writes the checksum of a table to the log file and stores it as table result of the instance.
+// In case of text output the checksum is written to STDOUT as well, followed by the checksums of its buckets as
+// <instance>.<table>#<bucket number>. However, the compare command and the baseline verification report their results instead.
+// The aggregation version is appended to the checksum, unless it is the default version v1 or a structure checksum,
+// as well as the number of retries, if the table has been retried, e.g. "(v2, 1 retry)".
+func writeChecksum(result tableResult) {
+	logPrefix := "[" + result.instance + "] -"
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, logPrefix, "Table:"+result.table+",", "Number of rows:", result.numRows, "Duration:", result.duration)
+
+	addResult(result)
+
+	text := pr.output == textOutput && pr.command != compareCommand && pr.verify == ""
+	if text {
+		line := fmt.Sprintf("%s:%s", result.instance+"."+result.table, result.checksum)
+		var notes []string
+		if aggregation != checksum.V1 && !pr.structure {
+			notes = append(notes, aggregation.String())
+		}
+		if result.retries > 0 {
+			notes = append(notes, retriesText(result.retries))
+		}
+		if len(notes) > 0 {
+			line += " (" + strings.Join(notes, ", ") + ")"
+		}
+		simplelog.Write(simplelog.STDOUT, line)
+	}
+	hashName := strings.ToUpper(hashAlgorithm.String()) + " " + aggregation.String()
+	simplelog.Write(simplelog.FILE, logPrefix, "Table:"+result.table+",", hashName+": "+result.checksum)
+	for i, b := range result.buckets {
+		if text {
+			simplelog.Write(simplelog.STDOUT, fmt.Sprintf("%s#%d:%s (%d rows, %s)", result.instance+"."+result.table, i+1, b.checksum, b.numRows, b.bucket))
+		}
+		simplelog.Write(simplelog.FILE, logPrefix, "Table:"+result.table+",", "Bucket:"+strconv.Itoa(i+1)+" ("+b.bucket+"),", "Number of rows:", b.numRows, hashName+": "+b.checksum)
+	}
+}
+
+// writeInterrupted writes the interruption of a table to the log file and stores it as table result of the instance.
+// In case of text output <instance>.<table>:INTERRUPTED is written to STDOUT as well, unless the compare command or
+// the baseline verification report their results instead.
+func writeInterrupted(result tableResult) {
+	writeStatus(result, interruptedStatus)
+}
+
+// writeSkipped writes a skipped table to the log file and stores it as table result of the instance.
+// In case of text output <instance>.<table>:SKIPPED is written to STDOUT as well, see writeInterrupted.
+func writeSkipped(result tableResult) {
+	writeStatus(result, skippedStatus)
+}
+
+// writeStatus writes the error of a table result, which has no checksum, and stores the result.
+func writeStatus(result tableResult, status string) {
+	logPrefix := "[" + result.instance + "] -"
+	simplelog.Write(simplelog.FILE, logPrefix, "Table:"+result.table+",", result.errorText())
+
+	addResult(result)
+
+	if pr.output == textOutput && pr.command != compareCommand && pr.verify == "" {
+		simplelog.Write(simplelog.STDOUT, result.instance+"."+result.table+":"+status)
+	}
+}
+
+// sortedResults returns the results of all instances ordered by instance name and the processing order of the tables.
+func sortedResults() []tableResult {
+	instances := make([]string, 0, len(tableResults))
+	for instance := range tableResults {
+		instances = append(instances, instance)
+	}
+	sort.Strings(instances)
+
+	var results []tableResult
+	for _, instance := range instances {
+		results = append(results, tableResults[instance]...)
+	}
+	return results
+}
+
+// errorText returns the error message of a result, an empty string if there was no error.
+func (r tableResult) errorText() string {
+	if r.err == nil {
+		return ""
+	}
+	return r.err.Error()
+}
+
+// writeResults writes all table results to STDOUT in the given output format.
+// Text output is written by writeChecksum as soon as a checksum is calculated.
+// Hint: The output is written by the logger, thus it is written after all previous log messages.
+func writeResults(format string) error {
+	var out bytes.Buffer
+	var err error
+
+	switch format {
+	case jsonOutput:
+		err = writeJSON(&out, sortedResults())
+	case csvOutput:
+		err = writeCSV(&out, sortedResults())
+	case junitOutput:
+		err = writeJUnit(&out, sortedResults())
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	simplelog.Write(simplelog.STDOUT, strings.TrimSuffix(out.String(), "\n"))
+	return nil
+}
+
+// JSON representation of a table result
+type jsonResult struct {
+	Instance    string       `json:"instance"`
+	DBMS        string       `json:"dbms"`
+	Schema      string       `json:"schema"`
+	Table       string       `json:"table"`
+	Rows        int          `json:"rows"`
+	Checksum    string       `json:"checksum"`
+	Aggregation string       `json:"aggregation"`
+	Duration    float64      `json:"duration"` // seconds
+	Retries     int          `json:"retries"`
+	Buckets     []jsonBucket `json:"buckets,omitempty"`
+	Skipped     bool         `json:"skipped,omitempty"` // the table wasn't started, because another table of the instance failed
+	Error       string       `json:"error,omitempty"`
+}
+
+// JSON representation of a bucket checksum
+type jsonBucket struct {
+	Bucket   string `json:"bucket"`
+	Rows     int    `json:"rows"`
+	Checksum string `json:"checksum"`
+}
+
+// writeJSON writes all table results as JSON array.
+func writeJSON(out io.Writer, results []tableResult) error {
+	jsonResults := make([]jsonResult, 0, len(results))
+	for _, r := range results {
+		var buckets []jsonBucket
+		for _, b := range r.buckets {
+			buckets = append(buckets, jsonBucket{Bucket: b.bucket, Rows: b.numRows, Checksum: b.checksum})
+		}
+		jsonResults = append(jsonResults, jsonResult{
+			Instance:    r.instance,
+			DBMS:        r.dbms,
+			Schema:      r.schema,
+			Table:       r.table,
+			Rows:        r.numRows,
+			Checksum:    r.checksum,
+			Aggregation: aggregation.String(),
+			Duration:    r.duration.Seconds(),
+			Retries:     r.retries,
+			Buckets:     buckets,
+			Skipped:     errors.Is(r.err, errSkipped),
+			Error:       r.errorText(),
+		})
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(jsonResults)
+}
+
+// writeCSV writes all table results as CSV including a header line.
+func writeCSV(out io.Writer, results []tableResult) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"instance", "dbms", "schema", "table", "rows", "checksum", "aggregation", "duration", "retries", "error"})
+	for _, r := range results {
+		w.Write([]string{r.instance, r.dbms, r.schema, r.table, strconv.Itoa(r.numRows), r.checksum, aggregation.String(), strconv.FormatFloat(r.duration.Seconds(), 'f', 3, 64), strconv.Itoa(r.retries), r.errorText()})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// JUnit XML representation of the table results
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitFailure `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes all table results as JUnit XML. Every instance is a test suite and every table a test case,
+// which fails if its checksum could not be calculated. A table, which wasn't started because another table failed, is skipped.
+func writeJUnit(out io.Writer, results []tableResult) error {
+	var suites junitTestSuites
+	var duration time.Duration
+	for _, r := range results {
+		if len(suites.Suites) == 0 || suites.Suites[len(suites.Suites)-1].Name != r.instance {
+			duration = 0
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: r.instance})
+		}
+		suite := &suites.Suites[len(suites.Suites)-1]
+		testCase := junitTestCase{Name: r.table, ClassName: r.instance, Time: strconv.FormatFloat(r.duration.Seconds(), 'f', 3, 64)}
+		if r.table == "" {
+			testCase.Name = r.instance
+		}
+		switch {
+		case errors.Is(r.err, errSkipped):
+			testCase.Skipped = &junitFailure{Message: r.err.Error()}
+			suite.Skipped++
+		case r.err != nil:
+			testCase.Failure = &junitFailure{Message: r.err.Error()}
+			suite.Failures++
+		default:
+			testCase.SystemOut = fmt.Sprintf("rows: %d, checksum: %s, aggregation: %s, retries: %d", r.numRows, r.checksum, aggregation, r.retries)
+			for i, b := range r.buckets {
+				testCase.SystemOut += fmt.Sprintf("\nbucket %d (%s): rows: %d, checksum: %s", i+1, b.bucket, b.numRows, b.checksum)
+			}
+		}
+		suite.Tests++
+		duration += r.duration
+		suite.Time = strconv.FormatFloat(duration.Seconds(), 'f', 3, 64)
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	xmlSuites, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, xml.Header+string(xmlSuites))
+	return err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// A table, which wasn't started because another table failed, is neither missing nor a failure.
+func TestSkippedResult(t *testing.T) {
+	results := []tableResult{
+		{instance: "sqlite.test", table: "T1", err: errors.New("table T1 failed")},
+		{instance: "sqlite.test", table: "T2", err: errSkipped},
+	}
+
+	var out bytes.Buffer
+	if err := writeJUnit(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`tests="2" failures="1" skipped="1"`, `<skipped message="` + errSkipped.Error() + `"></skipped>`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("JUnit output doesn't contain %s:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := writeJSON(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	var jsonResults []jsonResult
+	if err := json.Unmarshal(out.Bytes(), &jsonResults); err != nil {
+		t.Fatal(err)
+	}
+	if len(jsonResults) != 2 || jsonResults[0].Skipped || !jsonResults[1].Skipped {
+		t.Errorf("got JSON results %+v, want the second table skipped", jsonResults)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sabitor/simplelog"
+)
+
+// retry policy of transient errors, see the Retries, Retrybackoff and Retryerrors parameters
+type retryPolicy struct {
+	retries int           // maximum number of retries, 0 disables retries
+	backoff time.Duration // delay before the first retry, doubled after every retry
+	errors  []string      // additional error message patterns of transient errors
+}
+
+// commonRetry is the retry policy of the common section, which is the default of all instances.
+var commonRetry = retryPolicy{backoff: time.Second}
+
+// networkErrors are the error message patterns of transient network errors of all DBMS.
+// Hint: Some drivers return network errors as plain error messages.
+var networkErrors = []string{"connection reset by peer", "broken pipe", "connection refused", "i/o timeout", "unexpected EOF"}
+
+// transient returns true if an error is a transient error, which is a network error or one of the transient errors of the
+// dialect (e.g. a deadlock) or of the Retryerrors parameter. Errors of an interrupted run and statement timeouts aren't transient.
+func (e *engine) transient(ctx context.Context, err error) bool {
+	switch {
+	case ctx.Err() != nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE):
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range slices.Concat(networkErrors, e.dia.transientErrors(), e.cfg.retry.errors) {
+		if strings.Contains(msg, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry runs an operation and retries it after transient errors according to the retry policy of the instance.
+// The delay before a retry starts with the configured backoff and is doubled after every retry. It returns the number
+// of retries and the error of the last attempt.
+func (e *engine) retry(ctx context.Context, operation string, f func() error) (int, error) {
+	delay := e.cfg.retry.backoff
+	for retries := 0; ; retries++ {
+		err := f()
+		if err == nil || retries >= e.cfg.retry.retries || !e.transient(ctx, err) {
+			return retries, err
+		}
+		simplelog.Write(simplelog.MULTI, e.logPrefix(), formatMsg(mm067, operation, strconv.Itoa(retries+1), strconv.Itoa(e.cfg.retry.retries), delay.String()))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return retries, err
+		}
+		delay *= 2
+	}
+}
+
+// openInstance opens the database connection of an instance and returns the number of connection retries. The connection
+// of instances, whose checksums are compiled by the engine, is retried after transient errors according to the retry policy
+// of the instance.
+func openInstance(ctx context.Context, instance string) (*sql.DB, int, error) {
+	dbms := instanceName(instance)
+	provider, ok := dbms.(engineProvider)
+	if !ok {
+		db, err := dbms.openDB(ctx, instancePassword[instance])
+		return db, 0, err
+	}
+
+	var db *sql.DB
+	retries, err := provider.checksumEngine().retry(ctx, "Connection", func() error {
+		var err error
+		db, err = dbms.openDB(ctx, instancePassword[instance])
+		return err
+	})
+	return db, retries, err
+}
+
+// retriesText describes a number of retries, e.g. "2 retries".
+func retriesText(retries int) string {
+	if retries == 1 {
+		return "1 retry"
+	}
+	return strconv.Itoa(retries) + " retries"
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sabitor/simplelog"
+	"modernc.org/sqlite"
+
+	"md5tabsum/checksum"
+)
+
+type sqliteDB struct {
+	cfg  config
+	path string // SQLite specific
+}
+
+// SQLite has no built-in hash functions (MD5, SHA-1, SHA-256), no function to convert hex digits into a number and no
+// bitwise XOR aggregate function. Thus, they are implemented in Go and registered for all SQLite connections, the hash
+// functions by the name of the hash algorithm.
+func init() {
+	for _, h := range []checksum.Hash{checksum.MD5, checksum.SHA1, checksum.SHA256} {
+		sqlite.MustRegisterDeterministicScalarFunction(h.String(), 1, sqliteHash(h))
+	}
+	sqlite.MustRegisterDeterministicScalarFunction("hex_to_int", 1, sqliteHexToInt)
+	sqlite.MustRegisterFunction("bit_xor", &sqlite.FunctionImpl{
+		NArgs:         1,
+		Deterministic: true,
+		MakeAggregate: func(ctx sqlite.FunctionContext) (sqlite.AggregateFunction, error) { return &sqliteBitXor{}, nil },
+	})
+}
+
+// sqliteHash returns the hash function of a hash algorithm, which returns the lowercase hex digits of the hash of a value.
+// NULL values result in NULL.
+func sqliteHash(h checksum.Hash) func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	return func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		var data string
+		switch v := args[0].(type) {
+		case nil:
+			return nil, nil
+		case []byte:
+			data = string(v)
+		case string:
+			data = v
+		default:
+			data = fmt.Sprint(v)
+		}
+		return h.Sum(data), nil
+	}
+}
+
+// sqliteBitXor is the bitwise XOR aggregate function of integer values, NULL values are ignored.
+type sqliteBitXor struct {
+	xor int64
+}
+
+func (x *sqliteBitXor) Step(ctx *sqlite.FunctionContext, args []driver.Value) error {
+	switch v := args[0].(type) {
+	case nil:
+	case int64:
+		x.xor ^= v
+	default:
+		return fmt.Errorf("bit_xor: unsupported argument type %T", v)
+	}
+	return nil
+}
+
+// WindowInverse removes a value, which is the same as adding it again.
+func (x *sqliteBitXor) WindowInverse(ctx *sqlite.FunctionContext, args []driver.Value) error {
+	return x.Step(ctx, args)
+}
+
+func (x *sqliteBitXor) WindowValue(ctx *sqlite.FunctionContext) (driver.Value, error) {
+	return x.xor, nil
+}
+
+func (x *sqliteBitXor) Final(ctx *sqlite.FunctionContext) {}
+
+// sqliteHexToInt converts a string of hex digits into an integer, NULL values result in NULL.
+func sqliteHexToInt(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	var hexDigits string
+	switch v := args[0].(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		hexDigits = string(v)
+	case string:
+		hexDigits = v
+	default:
+		return nil, fmt.Errorf("hex_to_int: unsupported argument type %T", v)
+	}
+	return strconv.ParseInt(hexDigits, 16, 64)
+}
+
+func (s *sqliteDB) instance() string {
+	return s.cfg.instance
+}
+
+func (s *sqliteDB) schema() string {
+	if s.cfg.schema == "" {
+		return "main"
+	}
+	return s.cfg.schema
+}
+
+func (s *sqliteDB) table() []string {
+	return s.cfg.table
+}
+
+func (s *sqliteDB) file() string {
+	return s.path
+}
+
+func (s *sqliteDB) logPrefix() string {
+	return "[" + s.instance() + "] -"
+}
+
+// ----------------------------------------------------------------------------
+func (s *sqliteDB) openDB(ctx context.Context, password string) (*sql.DB, error) {
+	tableFilter := strings.Join(s.table(), ", ")
+	simplelog.ConditionalWrite(condition(pr.logLevel, debug), simplelog.FILE, s.logPrefix(), "Profile parameter:", "File:"+s.file()+",", "Schema:"+s.schema()+",", "Table:"+tableFilter)
+	// the database file is opened read-only, thus a missing file is not created but reported as error
+	dsn := "file:" + s.file() + "?mode=ro"
+	db, err := connectDB(ctx, "sqlite", dsn)
+	if err != nil {
+		simplelog.Write(simplelog.MULTI, s.logPrefix(), err.Error())
+		return db, err
+	}
+	return db, err
+}
+
+func (s *sqliteDB) closeDB(db *sql.DB) error {
+	return db.Close()
+}
+
+func (s *sqliteDB) queryDB(ctx context.Context, db *sql.DB) error {
+	return s.checksumEngine().run(ctx, db)
+}
+
+func (s *sqliteDB) checksumEngine() *engine {
+	cfg := s.cfg
+	cfg.schema = s.schema()
+	return newEngine(&cfg, s)
+}
+
+// ----------------------------------------------------------------------------
+// dialect
+
+func (s *sqliteDB) sessionStmt() []string {
+	return nil
+}
+
+func (s *sqliteDB) versionStmt() string {
+	return "select sqlite_version(), null"
+}
+
+func (s *sqliteDB) transientErrors() []string {
+	// SQLITE_BUSY and SQLITE_LOCKED
+	return []string{"database is locked", "database table is locked"}
+}
+
+func (s *sqliteDB) tableStmt(schema, table string) (string, []any) {
+	return "select NAME from PRAGMA_TABLE_LIST where SCHEMA=? and TYPE='table' and NAME like ?", []any{schema, table}
+}
+
+func (s *sqliteDB) columnStmt(schema, table string) (string, []any) {
+	return "select NAME, TYPE, CID+1 from PRAGMA_TABLE_INFO(?, ?) order by CID asc", []any{table, schema}
+}
+
+func (s *sqliteDB) structureStmt(schema, table string) (string, []any) {
+	// Hint: The declared data type includes length, precision and scale, e.g. DECIMAL(18,2).
+	return "select NAME, TYPE, null, null, null, CID+1, 1-\"notnull\", PK from PRAGMA_TABLE_INFO(?, ?) order by CID asc", []any{table, schema}
+}
+
+func (s *sqliteDB) quoteIdent(ident string) string {
+	return "\"" + strings.ReplaceAll(ident, "\"", "\"\"") + "\""
+}
+
+func (s *sqliteDB) quoteLiteral(literal string) string {
+	return "'" + strings.ReplaceAll(literal, "'", "''") + "'"
+}
+
+func (s *sqliteDB) timestampLiteral(value time.Time, class typeClass) string {
+	// Hint: SQLite has no date and time types, the values are usually stored as text without trailing zeros of the fraction.
+	if class == dateType {
+		return "'" + value.Format("2006-01-02") + "'"
+	}
+	return "'" + value.Format("2006-01-02 15:04:05.999999999") + "'"
+}
+
+// Hint: SQLite stores values by their type affinity, which is derived from the declared column type.
+func (s *sqliteDB) typeClass(columnType string) typeClass {
+	columnType = strings.ToUpper(columnType)
+	switch {
+	case strings.Contains(columnType, "CHAR"), strings.Contains(columnType, "CLOB"), strings.Contains(columnType, "TEXT"):
+		return charType
+	case strings.Contains(columnType, "TIME"), strings.Contains(columnType, "DATE"):
+		return timestampType
+	case strings.Contains(columnType, "BOOL"):
+		return booleanType
+	case strings.Contains(columnType, "DEC"), strings.Contains(columnType, "NUMERIC"):
+		return decimalType
+	case strings.Contains(columnType, "REAL"), strings.Contains(columnType, "FLOA"), strings.Contains(columnType, "DOUB"):
+		return floatType
+	default:
+		return otherType
+	}
+}
+
+func (s *sqliteDB) canonicalExpr(column string, class typeClass) string {
+	switch class {
+	case charType:
+		// calculate the hash of a string-type column to prevent a potential varchar(max) overflow of all concatenated columns
+		return "coalesce(" + s.hashExpr("rtrim("+column+")") + ", 'null')"
+	case timestampType:
+		// Hint: strftime supports milliseconds only (%f returns SS.SSS), the missing microsecond digits are filled up with zeros.
+		return "coalesce(strftime('%Y-%m-%d %H:%M:%S', " + column + ") || substr(strftime('%f', " + column + "), 3) || '000', 'null')"
+	case booleanType:
+		return "case when " + column + " is NULL then 'null' when " + column + " then '1' else '0' end"
+	case floatType:
+		// Hint: Floating point values without fractional digits are converted to text with a trailing '.0', e.g. 8192.0
+		return "coalesce(case when " + column + " = cast(" + column + " as integer) then cast(cast(" + column + " as integer) as text) else cast(" + column + " as text) end, 'null')"
+	default:
+		// numeric values of DECIMAL columns are stored without trailing zeros by the NUMERIC type affinity
+		return "coalesce(cast(" + column + " as text), 'null')"
+	}
+}
+
+func (s *sqliteDB) concatExpr(columns []string) string {
+	return strings.Join(columns, " || ")
+}
+
+// hashExpr returns the hash (lowercase hex digits) of a text expression by the registered hash function, e.g. sha256.
+func (s *sqliteDB) hashExpr(expr string) string {
+	return hashAlgorithm.String() + "(" + expr + ")"
+}
+
+func (s *sqliteDB) rowHashExpr(row string) string {
+	return s.hashExpr(row)
+}
+
+// hexToIntExpr converts 8 hex digits of a hash expression, starting at the given position, into an INTEGER.
+func (s *sqliteDB) hexToIntExpr(hash string, start int) string {
+	return "hex_to_int(substr(" + hash + ", " + strconv.Itoa(start) + ", 8))"
+}
+
+func (s *sqliteDB) partSumExpr(start int) string {
+	return "cast(sum(" + s.hexToIntExpr("ROWHASH", start) + ") as text)"
+}
+
+func (s *sqliteDB) partXorExpr(start int) string {
+	return "cast(bit_xor(" + s.hexToIntExpr("ROWHASH", start) + ") as text)"
+}
+
+func (s *sqliteDB) bucketExpr(hash string, buckets int) string {
+	return s.hexToIntExpr(hash, 1) + " % " + strconv.Itoa(buckets)
+}
+
+func (s *sqliteDB) checksumExpr() string {
+	return "count(1) NUMROWS, coalesce(" + s.hashExpr(strings.Join(partSumExprs(s), " || ")) + ", '" + hashAlgorithm.Empty() + "') CHECKSUM"
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sabitor/simplelog"
+)
+
+// collection of table column definition properties, which are part of the structure checksum
+type columnDefinition struct {
+	column
+	length      sql.NullInt64 // maximum length of character data types
+	precision   sql.NullInt64 // precision of numeric data types
+	scale       sql.NullInt64 // scale of numeric data types
+	nullable    bool
+	keyPosition int // position in the primary key, 0 if the column isn't part of the primary key
+}
+
+// typeArgs splits a data type into its lowercase name and its arguments, e.g. timestamp(6) with time zone into
+// "timestamp with time zone" and [6].
+var typeArgs = regexp.MustCompile(`^([^(]*)(?:\(([^)]*)\))?(.*)$`)
+
+// data type names of the DBMS grouped by their common data type
+var (
+	booleanTypes = []string{"boolean", "bool", "bit"}
+	integerTypes = []string{"tinyint", "smallint", "mediumint", "int", "integer", "bigint", "hugeint", "int2", "int4", "int8", "utinyint", "usmallint", "uinteger", "ubigint"}
+	decimalTypes = []string{"decimal", "numeric", "number", "dec"}
+	realTypes    = []string{"real", "float4", "binary_float"}
+	doubleTypes  = []string{"double", "double precision", "float8", "binary_double"}
+	charTypes    = []string{"char", "character", "nchar", "bpchar"}
+	varcharTypes = []string{"varchar", "varchar2", "nvarchar", "nvarchar2", "character varying", "varying character"}
+	textTypes    = []string{"text", "tinytext", "mediumtext", "longtext", "ntext", "clob", "nclob", "long", "string"}
+	binaryTypes  = []string{"binary", "varbinary", "blob", "tinyblob", "mediumblob", "longblob", "bytea", "raw", "long raw", "image"}
+	tzTypes      = []string{"timestamptz", "datetimeoffset", "timestamp with time zone", "timestamp with local time zone"}
+	timeTypes    = []string{"datetime", "datetime2", "smalldatetime", "timestamp", "timestamp without time zone"}
+)
+
+// normalizeType maps a DBMS specific data type to the common data type vocabulary of the structure checksum:
+// BOOLEAN, INTEGER, DECIMAL(p,s), REAL, DOUBLE, CHAR(n), VARCHAR(n), TEXT, DATE, TIME, TIMESTAMP, TIMESTAMP WITH TIME ZONE
+// and BINARY. Exact numeric data types with scale 0 are INTEGER, character data types without length are TEXT.
+// Any other data type is represented by its uppercase name. Length, precision and scale are taken from the data type
+// arguments, if the data type includes them, e.g. DECIMAL(18,2).
+func normalizeType(col columnDefinition) string {
+	parts := typeArgs.FindStringSubmatch(strings.ToLower(strings.TrimSpace(col.dataType)))
+	name := strings.Join(strings.Fields(parts[1]+" "+parts[3]), " ")
+	var args []int64
+	for _, arg := range strings.Split(parts[2], ",") {
+		if n, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64); err == nil {
+			args = append(args, n)
+		}
+	}
+	length, precision, scale := col.length, col.precision, col.scale
+	if len(args) > 0 {
+		length = sql.NullInt64{Int64: args[0], Valid: true}
+		precision = length
+		scale = sql.NullInt64{Valid: true}
+	}
+	if len(args) > 1 {
+		scale = sql.NullInt64{Int64: args[1], Valid: true}
+	}
+
+	in := func(names []string) bool {
+		for _, n := range names {
+			if name == n {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case in(booleanTypes):
+		return "BOOLEAN"
+	case in(integerTypes):
+		return "INTEGER"
+	case in(decimalTypes):
+		switch {
+		case scale.Valid && scale.Int64 == 0:
+			return "INTEGER"
+		case precision.Valid && scale.Valid:
+			return "DECIMAL(" + strconv.FormatInt(precision.Int64, 10) + "," + strconv.FormatInt(scale.Int64, 10) + ")"
+		default:
+			return "DECIMAL"
+		}
+	case name == "float":
+		// the precision of FLOAT is the number of binary digits of the mantissa
+		if precision.Valid && precision.Int64 > 0 && precision.Int64 <= 24 {
+			return "REAL"
+		}
+		return "DOUBLE"
+	case in(realTypes):
+		return "REAL"
+	case in(doubleTypes):
+		return "DOUBLE"
+	case in(charTypes):
+		if length.Valid && length.Int64 > 0 {
+			return "CHAR(" + strconv.FormatInt(length.Int64, 10) + ")"
+		}
+		return "CHAR"
+	case in(varcharTypes):
+		// Hint: The length of unlimited character data types is -1, e.g. varchar(max) of SQL Server.
+		if length.Valid && length.Int64 > 0 {
+			return "VARCHAR(" + strconv.FormatInt(length.Int64, 10) + ")"
+		}
+		return "TEXT"
+	case in(textTypes):
+		return "TEXT"
+	case in(binaryTypes):
+		return "BINARY"
+	case name == "date":
+		return "DATE"
+	case strings.HasPrefix(name, "time") && !strings.HasPrefix(name, "timestamp"):
+		return "TIME"
+	case in(tzTypes):
+		return "TIMESTAMP WITH TIME ZONE"
+	case in(timeTypes):
+		return "TIMESTAMP"
+	default:
+		return strings.ToUpper(name)
+	}
+}
+
+// definition returns the canonical definition of a column: <ordinal position>:<name>:<common data type>:<NULL|NOT NULL>:<primary key position>
+func (col columnDefinition) definition() string {
+	nullable := "NOT NULL"
+	if col.nullable {
+		nullable = "NULL"
+	}
+	return strings.Join([]string{strconv.Itoa(col.position), strings.ToUpper(col.name), normalizeType(col), nullable, strconv.Itoa(col.keyPosition)}, ":")
+}
+
+// columnDefinitions returns the column definitions of a table ordered by their ordinal position.
+func (e *engine) columnDefinitions(ctx context.Context, q querier, table string) ([]columnDefinition, error) {
+	var cols []columnDefinition
+
+	stmt, args := e.dia.structureStmt(e.cfg.schema, table)
+	simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "SQL[2]: "+stmt, "-", args)
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+	rowSet, err := q.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return cols, e.logError(err)
+	}
+	defer rowSet.Close()
+
+	for rowSet.Next() {
+		var col columnDefinition
+		var nullable int
+		if err := rowSet.Scan(&col.name, &col.dataType, &col.length, &col.precision, &col.scale, &col.position, &nullable, &col.keyPosition); err != nil {
+			return cols, e.logError(err)
+		}
+		col.nullable = nullable == 1
+		cols = append(cols, col)
+	}
+	if err := rowSet.Err(); err != nil {
+		return cols, e.logError(err)
+	}
+
+	return cols, nil
+}
+
+// structureChecksum compiles the number of columns and the checksum of the definition of a table in a dedicated database session.
+// The checksum is the hash of the canonical definitions of the selected columns, separated by commas. The ordinal
+// positions and the primary key positions are renumbered in the order of the selected columns and key columns. The column names of tables mapped by a compare pair are
+// replaced by the source column names, thus the structure checksums of both tables match.
+func (e *engine) structureChecksum(ctx context.Context, db *sql.DB, table string) (int, string, error) {
+	conn, err := e.openSession(ctx, db)
+	if err != nil {
+		return 0, "", err
+	}
+	defer e.closeSession(conn)
+
+	allCols, err := e.columnDefinitions(ctx, conn, table)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(allCols) == 0 {
+		return 0, "", e.logError(errors.New("Table " + table + " has no columns."))
+	}
+	cols := make([]column, 0, len(allCols))
+	for _, col := range allCols {
+		cols = append(cols, col.column)
+	}
+	if cols, err = e.selectColumns(table, cols); err != nil {
+		return 0, "", err
+	}
+
+	sourceNames := sourceColumns(e.cfg.instance, table)
+	selected := make([]columnDefinition, 0, len(cols))
+	var keyPositions []int
+	for i, col := range cols {
+		for _, def := range allCols {
+			if def.position != col.position {
+				continue
+			}
+			def.position = i + 1
+			if sourceName, mapped := sourceNames[strings.ToUpper(col.name)]; mapped {
+				def.name = sourceName
+			}
+			if def.keyPosition > 0 {
+				keyPositions = append(keyPositions, def.keyPosition)
+			}
+			selected = append(selected, def)
+		}
+	}
+	// the primary key positions are renumbered in the order of the selected key columns
+	slices.Sort(keyPositions)
+	definitions := make([]string, 0, len(selected))
+	for i, def := range selected {
+		if def.keyPosition > 0 {
+			def.keyPosition = slices.Index(keyPositions, def.keyPosition) + 1
+		}
+		definition := def.definition()
+		simplelog.ConditionalWrite(condition(pr.logLevel, trace), simplelog.FILE, e.logPrefix(), "Column", cols[i].position, "of "+table+":", cols[i].name, "("+cols[i].dataType+")", "Definition:", definition)
+		definitions = append(definitions, definition)
+	}
+
+	return len(definitions), hashAlgorithm.Sum(strings.Join(definitions, ",")), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// The ordinal and primary key positions of the selected columns are renumbered, thus a table with excluded columns has
+// the structure checksum of the same table without these columns.
+func TestStructureExcludedKey(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{
+		"create table S1 (A integer, B integer, C integer, D text, primary key (C, B, A))",
+		"create table S2 (A integer, C integer, D text, primary key (C, A))",
+		"create table S3 (A integer, C integer, D text, primary key (A, C))",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checksums := make(map[string]string)
+	for _, table := range []string{"S1", "S2", "S3"} {
+		cfg := config{instance: "sqlite.test", schema: "main", tableOpt: map[string]tableOptions{"S1": {exclude: []string{"B"}}}}
+		_, checksums[table], err = newEngine(&cfg, &sqliteDB{cfg: cfg}).structureChecksum(context.Background(), db, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if checksums["S1"] != checksums["S2"] {
+		t.Errorf("got structure checksum %s of S1 excluding B, want %s of S2", checksums["S1"], checksums["S2"])
+	}
+	if checksums["S2"] == checksums["S3"] {
+		t.Errorf("got the same structure checksum %s of S2 and S3, which differ by the order of their primary key columns", checksums["S2"])
+	}
+}
@@ -23,3 +23,4 @@ INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1,
 INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (10, 3, '2015-05-01', '2015-05-01 06:57:17.561', 'dMXnsZiS', null, 2, 0.91, 0.7779, 1);
 INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (11, 2, '2020-08-12', '2020-08-12 04:45:49.221', '++d42A@0iY', null, 2, 0.42, null, 1);
 INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (12, 1, '2017-09-01', '2017-09-01 21:57:08.432', '->dMX5sZ+~+~+~', '    321.', 2, 0.11, 8192, 0);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (13, 0, '2019-11-23', '2019-11-23 08:15:42.100', 'trail  ', 'x  ', 3, 1.50, -2.5, 1);
@@ -0,0 +1,26 @@
+CREATE TABLE T1
+( ID INTEGER, 
+  ID_DESC INTEGER, 
+  INSERTED_DATE DATE,
+  INSERTED_TS TIMESTAMP(6), 
+  CODE1 VARCHAR(20),
+  CODE2 CHAR(8),
+  NUMVAL1 INTEGER, 
+  NUMVAL2 DECIMAL(18,2), 
+  FLOATVAL1 DOUBLE,
+  ISVALID BOOLEAN
+);
+
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (1, 12, '2012-04-09', '2012-04-09 14:03:33.727', 'V}^>BdA', 'asdf', 1, 0.90, 40.1234, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (2, 11, '2017-09-15', '2017-09-15 14:56:50.349', 'c@sw}n10Ow', null, 1, 0.84, 32001.01, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (3, 10, '2016-12-11', '2016-12-11 22:49:49.646', '\^AY3] N', 'a4w/&', 1, 0.84, 8.22999, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (4, 9, '2016-10-15', '2016-10-15 01:05:54.385', 'WCGI]F@=======', '.,kerhf.', 2, 0.91, null, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (5, 8, '2017-09-11', '2017-09-11 11:05:59.952', 'swtLX^S{', null, 1, 0.84, null, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (6, 7, '2021-05-06', '2021-05-06 18:38:29.909', 'DqDYo<g<', '- na -', 2, 0.91, 9999.9, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (7, 6, '2018-03-08', '2018-03-08 12:43:11.693', 'w>easG]p', '/$%&H', 3, 0.14, 0.001231, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (8, 5, '2019-05-01', '2019-05-01 15:18:57.951', '8rFQZ4bBB', 'A', 4, -0.76, 10000.1, false);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (9, 4, '2012-03-02', '2012-03-02 07:26:49.211', 'ZJZA@0iY', '!"KKPP', 1, 0.84, null, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (10, 3, '2015-05-01', '2015-05-01 06:57:17.561', 'dMXnsZiS', null, 2, 0.91, 0.7779, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (11, 2, '2020-08-12', '2020-08-12 04:45:49.221', '++d42A@0iY', null, 2, 0.42, null, true);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (12, 1, '2017-09-01', '2017-09-01 21:57:08.432', '->dMX5sZ+~+~+~', '    321.', 2, 0.11, 8192, false);
+INSERT INTO T1 (ID, ID_DESC, INSERTED_DATE, INSERTED_TS, CODE1, CODE2, NUMVAL1, NUMVAL2, FLOATVAL1, ISVALID) VALUES (13, 0, '2019-11-23', '2019-11-23 08:15:42.100', 'trail  ', 'x  ', 3, 1.50, -2.5, true);
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sabitor/simplelog"
+)
+
+// runTimeout limits the duration of a run, see the common Timeout parameter. 0 doesn't limit the duration.
+var runTimeout time.Duration
+
+// statementTimeout limits the duration of every statement including fetching its rows, see the common
+// Statementtimeout parameter. 0 doesn't limit the duration.
+var statementTimeout time.Duration
+
+// interruptedStatus is written instead of the checksum of an interrupted table.
+const interruptedStatus string = "INTERRUPTED"
+
+// errInterrupted marks the error of a table or an instance, whose checksum calculation was interrupted by a signal or the run timeout.
+var errInterrupted = errors.New("interrupted")
+
+// runContext returns the context of a run, which is canceled by SIGINT or SIGTERM or after the run timeout. The running
+// statements of all instances are canceled by their drivers, thus the database sessions are closed as well.
+// A second signal terminates the process immediately. The returned function releases the context and the signal handler.
+func runContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	var timeout <-chan time.Time
+	if runTimeout > 0 {
+		timeout = time.After(runTimeout)
+	}
+
+	go func() {
+		var cause error
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			cause = fmt.Errorf("signal %s received", sig)
+		case <-timeout:
+			cause = fmt.Errorf("run timeout of %s exceeded", runTimeout)
+		case <-ctx.Done():
+			return
+		}
+		simplelog.Write(simplelog.MULTI, formatMsg(mm062, cause.Error()))
+		cancel(cause)
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
+
+// statementContext returns the context of a statement, which is canceled after the statement timeout.
+func statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if statementTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, statementTimeout, fmt.Errorf("statement timeout of %s exceeded", statementTimeout))
+}
+
+// interrupted returns errInterrupted including the cause of the interruption, if the run has been interrupted. The
+// original error is dropped in this case, because it is most probably the error of the canceled statement.
+func interrupted(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, errInterrupted) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", errInterrupted, context.Cause(ctx))
+}
+
+// errorCode returns the return code of a failed run, which is md5Interrupted if the run has been interrupted.
+func errorCode(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return md5Interrupted
+	}
+	return md5Error
+}